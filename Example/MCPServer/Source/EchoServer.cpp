@@ -24,6 +24,7 @@ namespace Implementation
         // 3. Register the descriptions of the Server's actual capabilities and their calling methods.
         MCP::Tool tool;
         tool.strName = Implementation::CEchoTask::TOOL_NAME;
+        tool.strTitle = Implementation::CEchoTask::TOOL_TITLE;
         tool.strDescription = Implementation::CEchoTask::TOOL_DESCRIPTION;
         std::string strInputSchema = Implementation::CEchoTask::TOOL_INPUT_SCHEMA;
         Json::Reader reader;
@@ -19,7 +19,7 @@ namespace Implementation
 
 	int CEchoTask::Cancel()
 	{
-		return MCP::ERRNO_OK;
+		return ProcessCallToolRequest::Cancel();
 	}
 
 	int CEchoTask::Execute()
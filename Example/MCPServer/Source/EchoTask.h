@@ -11,6 +11,7 @@ namespace Implementation
 	{
 	public:
 		static constexpr const char* TOOL_NAME = "echo";
+		static constexpr const char* TOOL_TITLE = u8"Echo";
 		static constexpr const char* TOOL_DESCRIPTION = u8"Receive the data sent by the client and then return the exact same data to the client.";
 		static constexpr const char* TOOL_INPUT_SCHEMA = u8R"({"type":"object","properties":{"input":{"type":"string","description":"client input data"}},"required":["input"]})";
 		static constexpr const char* TOOL_ARGUMENT_INPUT = "input";
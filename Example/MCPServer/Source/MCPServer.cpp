@@ -2,11 +2,32 @@
 #include <chrono>
 #include <thread>
 #include <atomic>
+#include <string>
+#include <vector>
 #include <signal.h>
 #include "EchoServer.h"
 #include "../../Source/Protocol/Public/Config.h"
 
 
+// Checks the tools the server registers and lists the problems on stderr, without serving
+int ValidateEchoServerTools()
+{
+    auto& server = Implementation::CEchoServer::GetInstance();
+    int iErrCode = server.Initialize();
+    if (MCP::ERRNO_OK != iErrCode)
+    {
+        std::cerr << "Initialize failed: " << iErrCode << std::endl;
+        return 1;
+    }
+
+    std::vector<std::string> vecIssues;
+    server.ValidateTools(vecIssues);
+    for (const auto& strIssue : vecIssues)
+        std::cerr << strIssue << std::endl;
+
+    return vecIssues.empty() ? 0 : 1;
+}
+
 int LaunchEchoServer()
 {
     // 0. Load configuration
@@ -42,6 +63,9 @@ int main(int argc, char* argv[])
 {
     signal(SIGINT, signal_handler);
     signal(SIGTERM, signal_handler);
-    
+
+    if (argc > 1 && std::string(argv[1]) == "--validate-tools")
+        return ValidateEchoServerTools();
+
     return LaunchEchoServer();
 }
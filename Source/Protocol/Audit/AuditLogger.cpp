@@ -0,0 +1,27 @@
+#include "AuditLogger.h"
+#include "../Public/PublicDef.h"
+
+namespace MCP
+{
+	CFileAuditLogger::CFileAuditLogger(const std::string& strPath)
+		: m_ofsFile(strPath, std::ios::out | std::ios::app)
+	{
+
+	}
+
+	int CFileAuditLogger::Record(const Json::Value& jEntry)
+	{
+		Json::FastWriter writer;
+		std::string strLine = writer.write(jEntry);
+
+		const std::lock_guard<std::mutex> _lock(m_mtxFile);
+		if (!m_ofsFile)
+			return ERRNO_INTERNAL_OUTPUT_ERROR;
+		m_ofsFile << strLine;
+		m_ofsFile.flush();
+		if (!m_ofsFile)
+			return ERRNO_INTERNAL_OUTPUT_ERROR;
+
+		return ERRNO_OK;
+	}
+}
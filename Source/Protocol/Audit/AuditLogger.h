@@ -0,0 +1,36 @@
+#pragma once
+// To ensure good cross-platform compatibility, the MCP namespace code uses standard C++ only.
+// Avoid using platform-specific system APIs unless absolutely necessary.
+
+#include <string>
+#include <fstream>
+#include <mutex>
+#include <json/json.h>
+
+namespace MCP
+{
+	// Receives one entry per tools/call: time, requestId, client, tool, arguments, outcome and,
+	// for failures, code and message. Record may be called from the session thread and the async task thread.
+	// The session's dead-letter logger uses the same interface for undeliverable notifications.
+	class CMCPAuditLogger
+	{
+	public:
+		virtual ~CMCPAuditLogger(){}
+
+		virtual int Record(const Json::Value& jEntry) = 0;
+	};
+
+	// Appends each entry as a JSON line to a file. stdout is not an option with the stdio transport,
+	// it carries the protocol messages.
+	class CFileAuditLogger : public CMCPAuditLogger
+	{
+	public:
+		CFileAuditLogger(const std::string& strPath);
+
+		int Record(const Json::Value& jEntry) override;
+
+	private:
+		std::mutex m_mtxFile;
+		std::ofstream m_ofsFile;
+	};
+}
@@ -6,6 +6,7 @@
 #include "../Public/PublicDef.h"
 #include "../Session/Session.h"
 #include "../Transport/Transport.h"
+#include "../Public/Config.h"
 
 namespace MCP
 {
@@ -25,6 +26,21 @@ namespace MCP
 			MCP::CMCPSession::GetInstance().SetServerInfo(serverInfo);
 		}
 
+		void SetInstructions(const std::string& strInstructions)
+		{
+			MCP::CMCPSession::GetInstance().SetServerInstructions(strInstructions);
+		}
+
+		void SetInstructionsFile(const std::string& strPath)
+		{
+			MCP::CMCPSession::GetInstance().SetServerInstructionsFile(strPath);
+		}
+
+		void SetClientInstructions(const std::string& strClientName, const std::string& strInstructions)
+		{
+			MCP::CMCPSession::GetInstance().SetServerClientInstructions(strClientName, strInstructions);
+		}
+
 		void SetTransport(const std::shared_ptr<MCP::CMCPTransport>& spTransport)
 		{
 			MCP::CMCPSession::GetInstance().SetTransport(spTransport);
@@ -45,10 +61,118 @@ namespace MCP
 			m_capabilities.prompts = prompts;
 		}
 
-		void RegisterServerTools(const std::vector<MCP::Tool>& tools, bool bPagination)
+		void RegisterServerLoggingCapabilities(const MCP::Logging& logging)
+		{
+			m_capabilities.logging = logging;
+		}
+
+		// pvecRenamed receives the tools renamed by DuplicateToolPolicy_Suffix, their tasks go under the new names
+		int RegisterServerTools(const std::vector<MCP::Tool>& tools, bool bPagination, std::vector<std::pair<std::size_t, std::string>>* pvecRenamed = nullptr)
 		{
 			MCP::CMCPSession::GetInstance().SetServerToolsPagination(bPagination);
-			MCP::CMCPSession::GetInstance().SetServerTools(tools);
+			return MCP::CMCPSession::GetInstance().SetServerTools(tools, pvecRenamed);
+		}
+
+		void SetDuplicateToolPolicy(MCP::CMCPSession::DuplicateToolPolicy ePolicy)
+		{
+			MCP::CMCPSession::GetInstance().SetServerDuplicateToolPolicy(ePolicy);
+		}
+
+		void SetToolArgumentCoercion(bool bCoercion)
+		{
+			MCP::CMCPSession::GetInstance().SetServerArgumentCoercion(bCoercion);
+		}
+
+		void SetStrictParsing(bool bStrict)
+		{
+			MCP::CMCPSession::GetInstance().SetServerStrictParsing(bStrict);
+		}
+
+		void SetToolSchemaVersionCheck(bool bCheck)
+		{
+			MCP::CMCPSession::GetInstance().SetServerSchemaVersionCheck(bCheck);
+		}
+
+		void SetToolTimingMeta(bool bTimingMeta)
+		{
+			MCP::CMCPSession::GetInstance().SetServerTimingMeta(bTimingMeta);
+		}
+
+		int SetCapabilitiesOverride(const Json::Value& jCapabilities)
+		{
+			return MCP::CMCPSession::GetInstance().SetServerCapabilitiesOverride(jCapabilities);
+		}
+
+		void SetDisabledMethods(const std::vector<std::string>& vecMethods)
+		{
+			MCP::CMCPSession::GetInstance().SetServerDisabledMethods(vecMethods);
+		}
+
+		void SetToolArgumentLimits(unsigned int nMaxBytes, unsigned int nMaxDepth)
+		{
+			MCP::CMCPSession::GetInstance().SetServerArgumentLimits(nMaxBytes, nMaxDepth);
+		}
+
+		void SetToolResultLimit(unsigned int nMaxBytes, bool bTruncate)
+		{
+			MCP::CMCPSession::GetInstance().SetServerMaxResultBytes(nMaxBytes, bTruncate);
+		}
+
+		void SetEmptyToolResultText(const std::string& strText)
+		{
+			MCP::CMCPSession::GetInstance().SetServerEmptyResultText(strText);
+		}
+
+		int RequestSampling(const Json::Value& jParams, Json::Value& jResult, unsigned int nTimeoutMs = 60000)
+		{
+			return MCP::CMCPSession::GetInstance().RequestSampling(jParams, jResult, nTimeoutMs);
+		}
+
+		void SetToolIdempotencyTTL(unsigned int nSeconds)
+		{
+			MCP::CMCPSession::GetInstance().SetServerIdempotencyTTL(nSeconds);
+		}
+
+		void SetMaxConcurrentToolCalls(unsigned int nMaxCalls, unsigned int nMaxWaitMs = 0)
+		{
+			MCP::CMCPSession::GetInstance().SetServerMaxConcurrentCalls(nMaxCalls, nMaxWaitMs);
+		}
+
+		void SetInitializeTimeout(unsigned int nMilliseconds)
+		{
+			MCP::CMCPSession::GetInstance().SetServerInitializeTimeout(nMilliseconds);
+		}
+
+		void SetErrorVerbosity(MCP::CMCPSession::ErrorVerbosity eVerbosity)
+		{
+			MCP::CMCPSession::GetInstance().SetServerErrorVerbosity(eVerbosity);
+		}
+
+		void SetAuditLogger(const std::shared_ptr<MCP::CMCPAuditLogger>& spAuditLogger, const std::vector<std::string>& vecRedactedArguments = {})
+		{
+			MCP::CMCPSession::GetInstance().SetServerAuditLogger(spAuditLogger);
+			MCP::CMCPSession::GetInstance().SetServerAuditRedactedArguments(vecRedactedArguments);
+		}
+
+		void SetDeadLetterLogger(const std::shared_ptr<MCP::CMCPAuditLogger>& spDeadLetterLogger)
+		{
+			MCP::CMCPSession::GetInstance().SetServerDeadLetterLogger(spDeadLetterLogger);
+		}
+
+		void SetRedactedFields(const std::vector<std::string>& vecFields)
+		{
+			MCP::CMCPSession::GetInstance().SetServerRedactedFields(vecFields);
+		}
+
+		void RegisterFallbackToolTask(std::shared_ptr<MCP::ProcessCallToolRequest> spTask)
+		{
+			MCP::CMCPSession::GetInstance().SetServerFallbackCallToolTask(spTask);
+		}
+
+		// Call after Initialize to check the registered tools before serving them
+		void ValidateTools(std::vector<std::string>& vecIssues) const
+		{
+			MCP::CMCPSession::GetInstance().ValidateServerTools(vecIssues);
 		}
 
 		void RegisterToolsTasks(const std::string& strToolName, std::shared_ptr<MCP::ProcessCallToolRequest> spTask)
@@ -69,6 +193,8 @@ namespace MCP
 			if (ERRNO_OK != iErrCode)
 				return iErrCode;
 
+			LogStartupSummary();
+
 			MCP::CMCPSession::GetInstance().Run();
 
 			return ERRNO_OK;
@@ -85,6 +211,44 @@ namespace MCP
 		CMCPServer() = default;
 		~CMCPServer() = default;
 
+		// Emits a one-line JSON summary of the effective setup on the transport's error channel.
+		// Secrets such as the API key are never included, only whether auth is enabled.
+		void LogStartupSummary()
+		{
+			auto& session = MCP::CMCPSession::GetInstance();
+			auto spTransport = session.GetTransport();
+			if (!spTransport)
+				return;
+			auto& config = MCP::Config::GetInstance();
+
+			Json::Value jSummary(Json::objectValue);
+			jSummary["event"] = "startup";
+			jSummary["server"] = session.GetServerInfo().strName;
+			jSummary["version"] = session.GetServerInfo().strVersion;
+			jSummary[MSG_KEY_PROTOCOL_VERSION] = PROTOCOL_VER;
+			jSummary["transport"] = std::dynamic_pointer_cast<CStdioTransport>(spTransport) ? "stdio" : "custom";
+			jSummary["host"] = config.GetHost();
+			jSummary["port"] = config.GetPort();
+			jSummary["https"] = config.IsHttpsEnabled();
+			jSummary["auth"] = config.IsAuthEnabled();
+
+			Json::Value jCapabilities(Json::arrayValue);
+			if (m_capabilities.tools.bExist)
+				jCapabilities.append(MSG_KEY_TOOLS);
+			if (m_capabilities.resources.bExist)
+				jCapabilities.append(MSG_KEY_RESOURCES);
+			if (m_capabilities.prompts.bExist)
+				jCapabilities.append(MSG_KEY_PROMPTS);
+			if (m_capabilities.logging.bExist)
+				jCapabilities.append(MSG_KEY_LOGGING);
+			jSummary[MSG_KEY_CAPABILITIES] = jCapabilities;
+			jSummary["toolCount"] = static_cast<Json::UInt>(session.GetServerTools().size());
+
+			Json::FastWriter writer;
+			writer.omitEndingLineFeed();
+			spTransport->Error(writer.write(jSummary));
+		}
+
 		MCP::ServerCapabilities m_capabilities;
 		std::unordered_map<std::string, std::shared_ptr<MCP::ProcessCallToolRequest>> m_hashCallToolsTasks;
 	};
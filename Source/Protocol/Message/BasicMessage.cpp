@@ -1,5 +1,6 @@
 #include "BasicMessage.h"
 #include <json/json.h>
+#include <algorithm>
 
 namespace MCP
 {
@@ -165,6 +166,25 @@ namespace MCP
 		return true;
 	}
 
+	////////////////////////////////////////////////////////////////////////////////////////
+	// Logging
+	int Logging::DoSerialize(Json::Value& jMsg) const
+	{
+		(void)jMsg;
+		return ERRNO_OK;
+	}
+
+	int Logging::DoDeserialize(const Json::Value& jMsg)
+	{
+		(void)jMsg;
+		return ERRNO_OK;
+	}
+
+	bool Logging::IsValid() const
+	{
+		return true;
+	}
+
 	////////////////////////////////////////////////////////////////////////////////////////
 	// ServerCapabilities
 	int ServerCapabilities::DoSerialize(Json::Value& jMsg) const
@@ -184,6 +204,7 @@ namespace MCP
 		fnSerializeMember(prompts, MSG_KEY_PROMPTS);
 		fnSerializeMember(resources, MSG_KEY_RESOURCES);
 		fnSerializeMember(tools, MSG_KEY_TOOLS);
+		fnSerializeMember(logging, MSG_KEY_LOGGING);
 
 		return ERRNO_OK;
 	}
@@ -203,6 +224,7 @@ namespace MCP
 		fnDeserializeMember(prompts, MSG_KEY_PROMPTS);
 		fnDeserializeMember(resources, MSG_KEY_RESOURCES);
 		fnDeserializeMember(tools, MSG_KEY_TOOLS);
+		fnDeserializeMember(logging, MSG_KEY_LOGGING);
 
 		return ERRNO_OK;
 	}
@@ -219,6 +241,9 @@ namespace MCP
 		Json::Value jName(strName);
 		jMsg[MSG_KEY_NAME] = jName;
 
+		Json::Value jTitle(strTitle.empty() ? strName : strTitle);
+		jMsg[MSG_KEY_TITLE] = jTitle;
+
 		if (!strDescription.empty())
 		{
 			Json::Value jDesc(strDescription);
@@ -227,25 +252,129 @@ namespace MCP
 
 		jMsg[MSG_KEY_INPUT_SCHEMA] = jInputSchema;
 
+		if (!vecTags.empty())
+		{
+			Json::Value jTags(Json::arrayValue);
+			for (const auto& strTag : vecTags)
+				jTags.append(strTag);
+			jMsg[MSG_KEY_META][MSG_KEY_TAGS] = jTags;
+		}
+
+		if (!strSchemaVersion.empty())
+			jMsg[MSG_KEY_META][MSG_KEY_SCHEMA_VERSION] = strSchemaVersion;
+
 		return ERRNO_OK;
 	}
 
-	int Tool::DoDeserialize(const Json::Value& jMsg)
+	int Tool::CheckDefinition(const Json::Value& jMsg, std::string& strIssue)
 	{
-		if (!jMsg.isMember(MSG_KEY_NAME) || !jMsg[MSG_KEY_NAME].isString())
+		if (!jMsg.isObject())
+		{
+			strIssue = u8"tool definition is not an object";
+			return ERRNO_PARSE_ERROR;
+		}
+		if (!jMsg.isMember(MSG_KEY_NAME) || !jMsg[MSG_KEY_NAME].isString() || jMsg[MSG_KEY_NAME].asString().empty())
+		{
+			strIssue = u8"missing name";
+			return ERRNO_PARSE_ERROR;
+		}
+		if (!jMsg.isMember(MSG_KEY_INPUT_SCHEMA) || !jMsg[MSG_KEY_INPUT_SCHEMA].isObject())
+		{
+			strIssue = u8"inputSchema must be an object";
 			return ERRNO_PARSE_ERROR;
+		}
+		auto& jSchema = jMsg[MSG_KEY_INPUT_SCHEMA];
+		if (jSchema.isMember("type") && jSchema["type"] != "object")
+		{
+			strIssue = u8"inputSchema type must be \"object\"";
+			return ERRNO_PARSE_ERROR;
+		}
+		if (jSchema.isMember("properties") && !jSchema["properties"].isObject())
+		{
+			strIssue = u8"inputSchema properties must be an object";
+			return ERRNO_PARSE_ERROR;
+		}
+		for (const char* lpcszKey : { MSG_KEY_TITLE, MSG_KEY_DESCRIPTION, MSG_KEY_CATEGORY })
+		{
+			if (jMsg.isMember(lpcszKey) && !jMsg[lpcszKey].isString())
+			{
+				strIssue = std::string(lpcszKey) + u8" must be a string";
+				return ERRNO_PARSE_ERROR;
+			}
+		}
+		if (jMsg.isMember(MSG_KEY_META) && !jMsg[MSG_KEY_META].isObject())
+		{
+			strIssue = std::string(MSG_KEY_META) + u8" must be an object";
+			return ERRNO_PARSE_ERROR;
+		}
+
+		return ERRNO_OK;
+	}
+
+	int Tool::DoDeserialize(const Json::Value& jMsg)
+	{
+		std::string strIssue;
+		int iErrCode = CheckDefinition(jMsg, strIssue);
+		if (ERRNO_OK != iErrCode)
+			return iErrCode;
 		strName = jMsg[MSG_KEY_NAME].asString();
 
+		if (jMsg.isMember(MSG_KEY_TITLE) && jMsg[MSG_KEY_TITLE].isString())
+			strTitle = jMsg[MSG_KEY_TITLE].asString();
+
 		if (jMsg.isMember(MSG_KEY_DESCRIPTION) && jMsg[MSG_KEY_DESCRIPTION].isString())
 			strDescription = jMsg[MSG_KEY_DESCRIPTION].asString();
 
-		if (!jMsg.isMember(MSG_KEY_INPUT_SCHEMA) || !jMsg[MSG_KEY_INPUT_SCHEMA].isObject())
-			return ERRNO_PARSE_ERROR;
 		jInputSchema = jMsg[MSG_KEY_INPUT_SCHEMA];
 
+		// Tags are read from _meta.tags, tool definitions written by hand may also carry
+		// a top-level "tags" array or a single "category" string.
+		vecTags.clear();
+		Json::Value jTags;
+		if (jMsg.isMember(MSG_KEY_META) && jMsg[MSG_KEY_META].isObject() && jMsg[MSG_KEY_META].isMember(MSG_KEY_TAGS))
+			jTags = jMsg[MSG_KEY_META][MSG_KEY_TAGS];
+		else if (jMsg.isMember(MSG_KEY_TAGS))
+			jTags = jMsg[MSG_KEY_TAGS];
+		if (jTags.isArray())
+		{
+			for (const auto& jTag : jTags)
+			{
+				if (jTag.isString())
+					vecTags.push_back(jTag.asString());
+			}
+		}
+		if (jMsg.isMember(MSG_KEY_CATEGORY) && jMsg[MSG_KEY_CATEGORY].isString()
+			&& std::find(vecTags.begin(), vecTags.end(), jMsg[MSG_KEY_CATEGORY].asString()) == vecTags.end())
+			vecTags.push_back(jMsg[MSG_KEY_CATEGORY].asString());
+
+		strSchemaVersion.clear();
+		if (jMsg.isMember(MSG_KEY_META) && jMsg[MSG_KEY_META].isObject()
+			&& jMsg[MSG_KEY_META].isMember(MSG_KEY_SCHEMA_VERSION) && jMsg[MSG_KEY_META][MSG_KEY_SCHEMA_VERSION].isString())
+			strSchemaVersion = jMsg[MSG_KEY_META][MSG_KEY_SCHEMA_VERSION].asString();
+
 		return ERRNO_OK;
 	}
 
+	std::string Tool::GetLocalizedDescription(const std::string& strLocale) const
+	{
+		if (strLocale.empty() || hashLocalizedDescriptions.empty())
+			return strDescription;
+
+		auto itrFound = hashLocalizedDescriptions.find(strLocale);
+		if (itrFound != hashLocalizedDescriptions.end())
+			return itrFound->second;
+
+		auto nSeparator = strLocale.find_first_of(u8"-_");
+		if (std::string::npos != nSeparator)
+		{
+			itrFound = hashLocalizedDescriptions.find(strLocale.substr(0, nSeparator));
+			if (itrFound != hashLocalizedDescriptions.end())
+				return itrFound->second;
+		}
+
+		return strDescription;
+	}
+
 	bool Tool::IsValid() const
 	{
 		if (strName.empty())
@@ -389,6 +518,60 @@ namespace MCP
 		return true;
 	}
 
+	////////////////////////////////////////////////////////////////////////////////////////
+	// ResourceLink
+	int ResourceLink::DoSerialize(Json::Value& jMsg) const
+	{
+		Json::Value jType(strType);
+		jMsg[MSG_KEY_TYPE] = jType;
+		Json::Value jUri(strUri);
+		jMsg[MSG_KEY_URI] = jUri;
+		Json::Value jName(strName);
+		jMsg[MSG_KEY_NAME] = jName;
+		if (!strDescription.empty())
+		{
+			Json::Value jDescription(strDescription);
+			jMsg[MSG_KEY_DESCRIPTION] = jDescription;
+		}
+		if (!strMimeType.empty())
+		{
+			Json::Value jMimeType(strMimeType);
+			jMsg[MSG_KEY_MIMETYPE] = jMimeType;
+		}
+
+		return ERRNO_OK;
+	}
+
+	int ResourceLink::DoDeserialize(const Json::Value& jMsg)
+	{
+		if (!jMsg.isMember(MSG_KEY_TYPE) || !jMsg[MSG_KEY_TYPE].isString())
+			return ERRNO_PARSE_ERROR;
+		strType = jMsg[MSG_KEY_TYPE].asString();
+		if (!jMsg.isMember(MSG_KEY_URI) || !jMsg[MSG_KEY_URI].isString())
+			return ERRNO_PARSE_ERROR;
+		strUri = jMsg[MSG_KEY_URI].asString();
+		if (!jMsg.isMember(MSG_KEY_NAME) || !jMsg[MSG_KEY_NAME].isString())
+			return ERRNO_PARSE_ERROR;
+		strName = jMsg[MSG_KEY_NAME].asString();
+		if (jMsg.isMember(MSG_KEY_DESCRIPTION) && jMsg[MSG_KEY_DESCRIPTION].isString())
+			strDescription = jMsg[MSG_KEY_DESCRIPTION].asString();
+		if (jMsg.isMember(MSG_KEY_MIMETYPE) && jMsg[MSG_KEY_MIMETYPE].isString())
+			strMimeType = jMsg[MSG_KEY_MIMETYPE].asString();
+
+		return ERRNO_OK;
+	}
+
+	bool ResourceLink::IsValid() const
+	{
+		if (strType.compare(CONST_RESOURCE_LINK) != 0)
+			return false;
+
+		if (strUri.empty() || strName.empty())
+			return false;
+
+		return true;
+	}
+
 	////////////////////////////////////////////////////////////////////////////////////////
 	// TextResourceContents
 	int TextResourceContents::DoSerialize(Json::Value& jMsg) const
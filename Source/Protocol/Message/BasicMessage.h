@@ -3,6 +3,8 @@
 // ǱҪ£ֹʹضϵͳƽ̨API
 
 #include "Message.h"
+#include <vector>
+#include <unordered_map>
 
 namespace MCP
 {
@@ -104,6 +106,20 @@ namespace MCP
 		int DoDeserialize(const Json::Value& jMsg) override;
 	};
 
+	struct Logging : public MCP::Message
+	{
+	public:
+		Logging()
+			: Message(MessageType_Logging, MessageCategory_Basic, false)
+		{
+
+		}
+
+		bool IsValid() const override;
+		int DoSerialize(Json::Value& jMsg) const override;
+		int DoDeserialize(const Json::Value& jMsg) override;
+	};
+
 	struct ServerCapabilities : public MCP::Message
 	{
 	public:
@@ -113,11 +129,13 @@ namespace MCP
 			prompts.bExist = false;
 			resources.bExist = false;
 			tools.bExist = false;
+			logging.bExist = false;
 		}
 
 		MCP::Prompts prompts;
 		MCP::Resources resources;
 		MCP::Tools tools;
+		MCP::Logging logging;
 
 		bool IsValid() const override;
 		int DoSerialize(Json::Value& jMsg) const override;
@@ -133,12 +151,25 @@ namespace MCP
 		}
 
 		std::string strName;
+		// Human-friendly display name, tools/list falls back to strName when empty
+		std::string strTitle;
 		std::string strDescription;
+		// Description variants keyed by locale ("de", "pt-BR"), strDescription is the default
+		std::unordered_map<std::string, std::string> hashLocalizedDescriptions;
 		Json::Value jInputSchema;
+		// Grouping hints for client UIs, sent as _meta.tags. Tools without tags are left ungrouped.
+		std::vector<std::string> vecTags;
+		// Bumped by the server whenever jInputSchema changes, sent as _meta.schemaVersion
+		std::string strSchemaVersion;
 
 		bool IsValid() const override;
 		int DoSerialize(Json::Value& jMsg) const override;
 		int DoDeserialize(const Json::Value& jMsg) override;
+
+		// Checks a tool definition before it is deserialized, strIssue names the first malformed field
+		static int CheckDefinition(const Json::Value& jMsg, std::string& strIssue);
+		// The variant for strLocale, then for its language ("de" for "de-AT"), else strDescription
+		std::string GetLocalizedDescription(const std::string& strLocale) const;
 	};
 
 	struct TextContent : public MCP::Message
@@ -225,6 +256,26 @@ namespace MCP
 		int DoDeserialize(const Json::Value& jMsg) override;
 	};
 
+	// Points at a resource the client can fetch itself instead of carrying its contents
+	struct ResourceLink : public MCP::Message
+	{
+		ResourceLink()
+			: Message(MessageType_ResourceLink, MessageCategory_Basic, false)
+		{
+
+		}
+
+		std::string strType;
+		std::string strUri;
+		std::string strName;
+		std::string strDescription;
+		std::string strMimeType;
+
+		bool IsValid() const override;
+		int DoSerialize(Json::Value& jMsg) const override;
+		int DoDeserialize(const Json::Value& jMsg) override;
+	};
+
 	struct ProgressToken : public MCP::Message
 	{
 		ProgressToken()
@@ -162,11 +162,13 @@ namespace MCP
             Json::Value jLevel(strLevel);
             jParams[MSG_KEY_LEVEL] = jLevel;
         }
-        if (!strText.empty())
+        if (!strLogger.empty())
         {
-            Json::Value jMessage(strText);
-            jParams[MSG_KEY_MESSAGE] = jMessage;
+            Json::Value jLogger(strLogger);
+            jParams[MSG_KEY_LOGGER] = jLogger;
         }
+        Json::Value jData(strText);
+        jParams[MSG_KEY_DATA] = jData;
         jMsg[MSG_KEY_PARAMS] = jParams;
 
         return ERRNO_OK;
@@ -183,8 +185,10 @@ namespace MCP
             auto& jParams = jMsg[MSG_KEY_PARAMS];
             if (jParams.isMember(MSG_KEY_LEVEL) && jParams[MSG_KEY_LEVEL].isString())
                 strLevel = jParams[MSG_KEY_LEVEL].asString();
-            if (jParams.isMember(MSG_KEY_MESSAGE) && jParams[MSG_KEY_MESSAGE].isString())
-                strText = jParams[MSG_KEY_MESSAGE].asString();
+            if (jParams.isMember(MSG_KEY_LOGGER) && jParams[MSG_KEY_LOGGER].isString())
+                strLogger = jParams[MSG_KEY_LOGGER].asString();
+            if (jParams.isMember(MSG_KEY_DATA) && jParams[MSG_KEY_DATA].isString())
+                strText = jParams[MSG_KEY_DATA].asString();
         }
         return ERRNO_OK;
     }
@@ -69,7 +69,7 @@ namespace MCP
 		int DoDeserialize(const Json::Value& jMsg) override;
 	};
 
-	// Structured log notification (utility in 2025-06-18 spec). This is optional for hosts.
+	// Structured log notification (notifications/message, logging utility in 2025-06-18 spec).
 	struct LogNotification : public MCP::Notification
 	{
 	public:
@@ -79,8 +79,9 @@ namespace MCP
 
 		}
 
-		// minimal fields: level and message
 		std::string strLevel;
+		std::string strLogger;
+		// Sent as the data member of the notification
 		std::string strText;
 
 		bool IsValid() const override;
@@ -94,6 +94,13 @@ namespace MCP
 			return ERRNO_INVALID_REQUEST;
 		auto& jClientInfo = jParams[MSG_KEY_CLIENT_INFO];
 
+		if (jParams.isMember(MSG_KEY_CAPABILITIES) && jParams[MSG_KEY_CAPABILITIES].isObject())
+			jCapabilities = jParams[MSG_KEY_CAPABILITIES];
+
+		if (jParams.isMember(MSG_KEY_META) && jParams[MSG_KEY_META].isObject()
+			&& jParams[MSG_KEY_META].isMember(MSG_KEY_LOCALE) && jParams[MSG_KEY_META][MSG_KEY_LOCALE].isString())
+			strLocale = jParams[MSG_KEY_META][MSG_KEY_LOCALE].asString();
+
 		return clientInfo.DoDeserialize(jClientInfo);
 	}
 
@@ -108,6 +115,94 @@ namespace MCP
 		return true;
 	}
 
+	////////////////////////////////////////////////////////////////////////////////////////
+	// CreateMessageRequest
+	int CreateMessageRequest::DoSerialize(Json::Value& jMsg) const
+	{
+		int iErrCode = Request::DoSerialize(jMsg);
+		if (ERRNO_OK != iErrCode)
+			return iErrCode;
+
+		Json::Value jMergedParams = jParams;
+		if (jMsg.isMember(MSG_KEY_PARAMS) && jMsg[MSG_KEY_PARAMS].isObject())
+		{
+			for (const auto& strKey : jMsg[MSG_KEY_PARAMS].getMemberNames())
+				jMergedParams[strKey] = jMsg[MSG_KEY_PARAMS][strKey];
+		}
+		jMsg[MSG_KEY_PARAMS] = jMergedParams;
+
+		return ERRNO_OK;
+	}
+
+	int CreateMessageRequest::DoDeserialize(const Json::Value& jMsg)
+	{
+		int iErrCode = Request::DoDeserialize(jMsg);
+		if (ERRNO_OK != iErrCode)
+			return iErrCode;
+
+		if (!jMsg.isMember(MSG_KEY_PARAMS) || !jMsg[MSG_KEY_PARAMS].isObject())
+			return ERRNO_INVALID_REQUEST;
+		jParams = jMsg[MSG_KEY_PARAMS];
+
+		return ERRNO_OK;
+	}
+
+	bool CreateMessageRequest::IsValid() const
+	{
+		if (!Request::IsValid())
+			return false;
+
+		if (strMethod.compare(METHOD_SAMPLING_CREATE_MESSAGE) != 0)
+			return false;
+
+		return jParams.isObject();
+	}
+
+	////////////////////////////////////////////////////////////////////////////////////////
+	// SetLevelRequest
+	int SetLevelRequest::DoSerialize(Json::Value& jMsg) const
+	{
+		int iErrCode = Request::DoSerialize(jMsg);
+		if (ERRNO_OK != iErrCode)
+			return iErrCode;
+
+		Json::Value jParams(Json::objectValue);
+		if (jMsg.isMember(MSG_KEY_PARAMS) && jMsg[MSG_KEY_PARAMS].isObject())
+			jParams = jMsg[MSG_KEY_PARAMS];
+		Json::Value jLevel(strLevel);
+		jParams[MSG_KEY_LEVEL] = jLevel;
+		jMsg[MSG_KEY_PARAMS] = jParams;
+
+		return ERRNO_OK;
+	}
+
+	int SetLevelRequest::DoDeserialize(const Json::Value& jMsg)
+	{
+		int iErrCode = Request::DoDeserialize(jMsg);
+		if (ERRNO_OK != iErrCode)
+			return iErrCode;
+
+		if (!jMsg.isMember(MSG_KEY_PARAMS) || !jMsg[MSG_KEY_PARAMS].isObject())
+			return ERRNO_INVALID_REQUEST;
+		auto& jParams = jMsg[MSG_KEY_PARAMS];
+		if (!jParams.isMember(MSG_KEY_LEVEL) || !jParams[MSG_KEY_LEVEL].isString())
+			return ERRNO_INVALID_REQUEST;
+		strLevel = jParams[MSG_KEY_LEVEL].asString();
+
+		return ERRNO_OK;
+	}
+
+	bool SetLevelRequest::IsValid() const
+	{
+		if (!Request::IsValid())
+			return false;
+
+		if (strMethod.compare(METHOD_LOGGING_SET_LEVEL) != 0)
+			return false;
+
+		return !strLevel.empty();
+	}
+
 	////////////////////////////////////////////////////////////////////////////////////////
 	// ListToolsRequest
 	int ListToolsRequest::DoSerialize(Json::Value& jMsg) const
@@ -187,14 +282,36 @@ namespace MCP
 			return ERRNO_INVALID_REQUEST;
 		strName = jParams[MSG_KEY_NAME].asString();
 
-		if (jParams.isMember(MSG_KEY_ARGUMENTS) && jParams[MSG_KEY_ARGUMENTS].isObject())
+		// Non-object arguments are kept so the session can reject them against the tool's schema.
+		if (jParams.isMember(MSG_KEY_ARGUMENTS) && !jParams[MSG_KEY_ARGUMENTS].isNull())
 		{
 			jArguments = jParams[MSG_KEY_ARGUMENTS];
 		}
 
+		if (jParams.isMember(MSG_KEY_META) && jParams[MSG_KEY_META].isObject())
+		{
+			jMeta = jParams[MSG_KEY_META];
+			if (jMeta.isMember(MSG_KEY_IDEMPOTENCY_KEY) && jMeta[MSG_KEY_IDEMPOTENCY_KEY].isString())
+				strIdempotencyKey = jMeta[MSG_KEY_IDEMPOTENCY_KEY].asString();
+		}
+
 		return ERRNO_OK;
 	}
 
+	Json::Value CallToolRequest::GetEchoedMeta() const
+	{
+		Json::Value jEchoed(Json::objectValue);
+		if (!jMeta.isObject())
+			return jEchoed;
+
+		jEchoed = jMeta;
+		jEchoed.removeMember(MSG_KEY_PROGRESS_TOKEN);
+		jEchoed.removeMember(MSG_KEY_IDEMPOTENCY_KEY);
+		jEchoed.removeMember(MSG_KEY_SCHEMA_VERSION);
+
+		return jEchoed;
+	}
+
 	bool CallToolRequest::IsValid() const
 	{
 		if (!Request::IsValid())
@@ -37,6 +37,10 @@ namespace MCP
 
 		std::string strProtocolVer;
 		Implementation clientInfo;
+		// params.capabilities as declared by the client, e.g. {"sampling":{}}
+		Json::Value jCapabilities{ Json::objectValue };
+		// params._meta.locale, e.g. "de-AT"; empty when the client sent none
+		std::string strLocale;
 
 		bool IsValid() const override;
 		int DoSerialize(Json::Value& jMsg) const override;
@@ -57,6 +61,40 @@ namespace MCP
 		int DoDeserialize(const Json::Value& jMsg) override;
 	};
 
+	struct SetLevelRequest : public MCP::Request
+	{
+	public:
+		SetLevelRequest(bool bNeedIdentity)
+			: Request(MessageType_SetLevelRequest, bNeedIdentity)
+		{
+
+		}
+
+		std::string strLevel;
+
+		bool IsValid() const override;
+		int DoSerialize(Json::Value& jMsg) const override;
+		int DoDeserialize(const Json::Value& jMsg) override;
+	};
+
+	// Sent by the server to have the client's model generate a message
+	struct CreateMessageRequest : public MCP::Request
+	{
+	public:
+		CreateMessageRequest(bool bNeedIdentity)
+			: Request(MessageType_CreateMessageRequest, bNeedIdentity)
+		{
+			strMethod = METHOD_SAMPLING_CREATE_MESSAGE;
+		}
+
+		// messages, maxTokens, systemPrompt, modelPreferences... as defined by the spec
+		Json::Value jParams{ Json::objectValue };
+
+		bool IsValid() const override;
+		int DoSerialize(Json::Value& jMsg) const override;
+		int DoDeserialize(const Json::Value& jMsg) override;
+	};
+
 	struct ListToolsRequest : public MCP::Request
 	{
 	public:
@@ -132,6 +170,14 @@ namespace MCP
 
 		std::string strName;
 		Json::Value jArguments;
+		// Optional params._meta.idempotencyKey, retries carrying the same key get the cached result
+		std::string strIdempotencyKey;
+		// params._meta as sent by the client
+		Json::Value jMeta;
+
+		// The client's _meta without the keys the SDK consumes (progressToken, idempotencyKey, schemaVersion),
+		// echoed back on the result so clients can correlate it
+		Json::Value GetEchoedMeta() const;
 
 		bool IsValid() const override;
 		int DoSerialize(Json::Value& jMsg) const override;
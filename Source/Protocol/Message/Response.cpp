@@ -48,6 +48,8 @@ namespace MCP
 		jError[MSG_KEY_CODE] = jCode;
 		Json::Value jMessage(strMesage);
 		jError[MSG_KEY_MESSAGE] = jMessage;
+		if (!jData.isNull())
+			jError[MSG_KEY_DATA] = jData;
 
 		jMsg[MSG_KEY_ERROR] = jError;
 
@@ -56,7 +58,23 @@ namespace MCP
 
 	int ErrorResponse::DoDeserialize(const Json::Value& jMsg)
 	{
-		return Response::DoDeserialize(jMsg);
+		int iErrCode = Response::DoDeserialize(jMsg);
+		if (ERRNO_OK != iErrCode)
+			return iErrCode;
+
+		if (!jMsg.isMember(MSG_KEY_ERROR) || !jMsg[MSG_KEY_ERROR].isObject())
+			return ERRNO_INVALID_RESPONSE;
+		auto& jError = jMsg[MSG_KEY_ERROR];
+		if (!jError.isMember(MSG_KEY_CODE) || !jError[MSG_KEY_CODE].isIntegral())
+			return ERRNO_INVALID_RESPONSE;
+		iCode = jError[MSG_KEY_CODE].asInt();
+		if (!jError.isMember(MSG_KEY_MESSAGE) || !jError[MSG_KEY_MESSAGE].isString())
+			return ERRNO_INVALID_RESPONSE;
+		strMesage = jError[MSG_KEY_MESSAGE].asString();
+		if (jError.isMember(MSG_KEY_DATA))
+			jData = jError[MSG_KEY_DATA];
+
+		return ERRNO_OK;
 	}
 
 	bool ErrorResponse::IsValid() const
@@ -87,13 +105,23 @@ namespace MCP
 			
 			return ERRNO_OK;
 		};
-		int iErrCode = fnSerializeMember(capabilities, MSG_KEY_CAPABILITIES);
+		int iErrCode = ERRNO_OK;
+		if (jCapabilitiesOverride.isObject())
+			jResult[MSG_KEY_CAPABILITIES] = jCapabilitiesOverride;
+		else
+			iErrCode = fnSerializeMember(capabilities, MSG_KEY_CAPABILITIES);
 		if (ERRNO_OK != iErrCode)
 			return iErrCode;
 		iErrCode = fnSerializeMember(implServerInfo, MSG_KEY_SERVER_INFO);
 		if (ERRNO_OK != iErrCode)
 			return iErrCode;
 
+		if (!strInstructions.empty())
+		{
+			Json::Value jInstructions(strInstructions);
+			jResult[MSG_KEY_INSTRUCTIONS] = jInstructions;
+		}
+
 		iErrCode = Response::DoSerialize(jMsg);
 		if (ERRNO_OK != iErrCode)
 			return iErrCode;
@@ -118,6 +146,49 @@ namespace MCP
 		return true;
 	}
 
+	////////////////////////////////////////////////////////////////////////////////////////
+	// PingResult
+	int PingResult::DoSerialize(Json::Value& jMsg) const
+	{
+		// The spec requires an empty result object, not a missing or null result
+		Json::Value jResult(Json::objectValue);
+		jMsg[MSG_KEY_RESULT] = jResult;
+
+		return Response::DoSerialize(jMsg);
+	}
+
+	////////////////////////////////////////////////////////////////////////////////////////
+	// EmptyResult
+	int EmptyResult::DoSerialize(Json::Value& jMsg) const
+	{
+		Json::Value jResult(Json::objectValue);
+		jMsg[MSG_KEY_RESULT] = jResult;
+
+		return Response::DoSerialize(jMsg);
+	}
+
+	////////////////////////////////////////////////////////////////////////////////////////
+	// CreateMessageResult
+	int CreateMessageResult::DoSerialize(Json::Value& jMsg) const
+	{
+		jMsg[MSG_KEY_RESULT] = jResult;
+
+		return Response::DoSerialize(jMsg);
+	}
+
+	int CreateMessageResult::DoDeserialize(const Json::Value& jMsg)
+	{
+		int iErrCode = Response::DoDeserialize(jMsg);
+		if (ERRNO_OK != iErrCode)
+			return iErrCode;
+
+		if (!jMsg.isMember(MSG_KEY_RESULT) || !jMsg[MSG_KEY_RESULT].isObject())
+			return ERRNO_INVALID_RESPONSE;
+		jResult = jMsg[MSG_KEY_RESULT];
+
+		return ERRNO_OK;
+	}
+
 	////////////////////////////////////////////////////////////////////////////////////////
 	// ListToolsResult
 	int ListToolsResult::DoSerialize(Json::Value& jMsg) const
@@ -148,7 +219,39 @@ namespace MCP
 
 	int ListToolsResult::DoDeserialize(const Json::Value& jMsg)
 	{
-		return Response::DoDeserialize(jMsg);
+		int iErrCode = Response::DoDeserialize(jMsg);
+		if (ERRNO_OK != iErrCode)
+			return iErrCode;
+
+		if (!jMsg.isMember(MSG_KEY_RESULT) || !jMsg[MSG_KEY_RESULT].isObject())
+			return ERRNO_INVALID_RESPONSE;
+		auto& jResult = jMsg[MSG_KEY_RESULT];
+		if (!jResult.isMember(MSG_KEY_TOOLS) || !jResult[MSG_KEY_TOOLS].isArray())
+			return ERRNO_INVALID_RESPONSE;
+
+		vecTools.clear();
+		vecInvalidTools.clear();
+		auto& jTools = jResult[MSG_KEY_TOOLS];
+		for (Json::ArrayIndex i = 0; i < jTools.size(); ++i)
+		{
+			std::string strIssue;
+			MCP::Tool tool;
+			if (ERRNO_OK != MCP::Tool::CheckDefinition(jTools[i], strIssue) || ERRNO_OK != tool.DoDeserialize(jTools[i]))
+			{
+				std::string strEntry = std::string(MSG_KEY_TOOLS) + u8"[" + std::to_string(i) + u8"]";
+				if (jTools[i].isObject() && jTools[i].isMember(MSG_KEY_NAME) && jTools[i][MSG_KEY_NAME].isString())
+					strEntry += u8" " + jTools[i][MSG_KEY_NAME].asString();
+				vecInvalidTools.push_back(strEntry + u8": " + strIssue);
+				continue;
+			}
+			vecTools.push_back(tool);
+		}
+
+		strNextCursor.clear();
+		if (jResult.isMember(MSG_KEY_NEXT_CURSOR) && jResult[MSG_KEY_NEXT_CURSOR].isString())
+			strNextCursor = jResult[MSG_KEY_NEXT_CURSOR].asString();
+
+		return ERRNO_OK;
 	}
 
 	bool ListToolsResult::IsValid() const
@@ -184,8 +287,17 @@ namespace MCP
 			if (ERRNO_OK == embedded.DoSerialize(jEmbedded))
 				jContent.append(jEmbedded);
 		}
+		for (auto& link : vecResourceLinks)
+		{
+			Json::Value jLink(Json::objectValue);
+			if (link.IsValid() && ERRNO_OK == link.DoSerialize(jLink))
+				jContent.append(jLink);
+		}
 		jResult[MSG_KEY_CONTENT] = jContent;
 
+		if (jMeta.isObject() && !jMeta.empty())
+			jResult[MSG_KEY_META] = jMeta;
+
 		jMsg[MSG_KEY_RESULT] = jResult;
 
 		return Response::DoSerialize(jMsg);
@@ -278,9 +390,7 @@ namespace MCP
 
 	bool CallToolResult::IsValid() const
 	{
-		if (vecTextContent.empty() && vecImageContent.empty() && vecEmbeddedResource.empty())
-			return false;
-
-		return true;
+		// An empty content array is valid, e.g. for a fire-and-forget actuation that returns nothing
+		return Response::IsValid();
 	}
 }
\ No newline at end of file
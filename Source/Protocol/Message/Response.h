@@ -34,6 +34,8 @@ namespace MCP
 
 		int iCode{ 0 };
 		std::string strMesage;
+		// Optional error.data, omitted when null
+		Json::Value jData;
 
 		bool IsValid() const override;
 		int DoSerialize(Json::Value& jMsg) const override;
@@ -51,7 +53,10 @@ namespace MCP
 
 		std::string strProtocolVersion;
 		MCP::ServerCapabilities capabilities;
+		// Sent instead of capabilities when it is an object
+		Json::Value jCapabilitiesOverride;
 		MCP::Implementation implServerInfo;
+		std::string strInstructions;
 
 		bool IsValid() const override;
 		int DoSerialize(Json::Value& jMsg) const override;
@@ -68,10 +73,43 @@ namespace MCP
 		}
 
 		bool IsValid() const override { return Response::IsValid(); }
-		int DoSerialize(Json::Value& jMsg) const override { return Response::DoSerialize(jMsg); }
+		int DoSerialize(Json::Value& jMsg) const override;
+		int DoDeserialize(const Json::Value& jMsg) override { return Response::DoDeserialize(jMsg); }
+	};
+
+	// Result with an empty object, for requests that only need an acknowledgement.
+	struct EmptyResult : public MCP::Response
+	{
+	public:
+		EmptyResult(bool bNeedIdentity)
+			: Response(MessageType_EmptyResult, bNeedIdentity)
+		{
+
+		}
+
+		bool IsValid() const override { return Response::IsValid(); }
+		int DoSerialize(Json::Value& jMsg) const override;
 		int DoDeserialize(const Json::Value& jMsg) override { return Response::DoDeserialize(jMsg); }
 	};
 
+	// The client's answer to a CreateMessageRequest
+	struct CreateMessageResult : public MCP::Response
+	{
+	public:
+		CreateMessageResult(bool bNeedIdentity)
+			: Response(MessageType_CreateMessageResult, bNeedIdentity)
+		{
+
+		}
+
+		// role, content, model, stopReason as sent by the client
+		Json::Value jResult{ Json::objectValue };
+
+		bool IsValid() const override { return Response::IsValid(); }
+		int DoSerialize(Json::Value& jMsg) const override;
+		int DoDeserialize(const Json::Value& jMsg) override;
+	};
+
 	struct ListToolsResult : public MCP::Response
 	{
 	public:
@@ -83,6 +121,9 @@ namespace MCP
 
 		std::vector<MCP::Tool> vecTools;
 		std::string strNextCursor;
+		// Filled when deserializing: one "tools[index] name: issue" entry per malformed tool.
+		// Malformed tools are left out of vecTools instead of failing the whole list.
+		std::vector<std::string> vecInvalidTools;
 
 		bool IsValid() const override;
 		int DoSerialize(Json::Value& jMsg) const override;
@@ -150,9 +191,12 @@ namespace MCP
 		}
 
 		bool bIsError{ false };
+		// Sent as result._meta when not empty
+		Json::Value jMeta{ Json::objectValue };
 		std::vector<MCP::TextContent> vecTextContent;
 		std::vector<MCP::ImageContent> vecImageContent;
 		std::vector<MCP::EmbeddedResource> vecEmbeddedResource;
+		std::vector<MCP::ResourceLink> vecResourceLinks;
 
 		bool IsValid() const override;
 		int DoSerialize(Json::Value& jMsg) const override;
@@ -1,4 +1,5 @@
 #include "Config.h"
+#include "PublicDef.h"
 #include <fstream>
 #include <sstream>
 #include <algorithm>
@@ -54,6 +55,15 @@ namespace MCP
         return ERRNO_OK;
     }
 
+    bool Config::HasKey(const std::string& section, const std::string& key) const
+    {
+        auto sectionIt = m_config.find(section);
+        if (sectionIt == m_config.end())
+            return false;
+
+        return sectionIt->second.count(key) > 0;
+    }
+
     std::string Config::GetString(const std::string& section, const std::string& key, const std::string& defaultValue) const
     {
         auto sectionIt = m_config.find(section);
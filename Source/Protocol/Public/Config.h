@@ -20,6 +20,7 @@ namespace MCP
         int LoadFromFile(const std::string& configPath);
 
         // Get configuration values
+        bool HasKey(const std::string& section, const std::string& key) const;
         std::string GetString(const std::string& section, const std::string& key, const std::string& defaultValue = "") const;
         int GetInt(const std::string& section, const std::string& key, int defaultValue = 0) const;
         bool GetBool(const std::string& section, const std::string& key, bool defaultValue = false) const;
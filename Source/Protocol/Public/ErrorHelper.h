@@ -0,0 +1,68 @@
+#pragma once
+
+#include "PublicDef.h"
+
+namespace MCP
+{
+	namespace ErrorHelper
+	{
+		// Maps an internal error code to the code sent in an error response.
+		// Codes that only make sense inside the SDK (transport and message plumbing) are reported as internal errors.
+		inline int to_response_code(int iErrCode)
+		{
+			switch (iErrCode)
+			{
+				case ERRNO_PARSE_ERROR:
+				case ERRNO_INVALID_REQUEST:
+				case ERRNO_METHOD_NOT_FOUND:
+				case ERRNO_INVALID_PARAMS:
+				case ERRNO_INTERNAL_ERROR:
+				case ERRNO_SERVER_BUSY:
+				case ERRNO_UNAUTHORIZED:
+				case ERRNO_FORBIDDEN:
+					return iErrCode;
+				case ERRNO_OK:
+				case ERRNO_INVALID_RESPONSE:
+				case ERRNO_INVALID_NOTIFICATION:
+				case ERRNO_INTERNAL_INPUT_TERMINATE:
+				case ERRNO_INTERNAL_INPUT_ERROR:
+				case ERRNO_INTERNAL_OUTPUT_ERROR:
+					return ERRNO_INTERNAL_ERROR;
+				default:
+					return iErrCode;
+			}
+		}
+
+		// Whether the code reports a fault in the client's request rather than a failure on the server side.
+		inline bool is_client_error(int iCode)
+		{
+			switch (iCode)
+			{
+				case ERRNO_PARSE_ERROR:
+				case ERRNO_INVALID_REQUEST:
+				case ERRNO_METHOD_NOT_FOUND:
+				case ERRNO_INVALID_PARAMS:
+					return true;
+				default:
+					return false;
+			}
+		}
+
+		// Default message for an error response code, empty for codes without one.
+		inline const char* get_message(int iCode)
+		{
+			switch (iCode)
+			{
+				case ERRNO_PARSE_ERROR: return ERROR_MESSAGE_PARSE_ERROR;
+				case ERRNO_INVALID_REQUEST: return ERROR_MESSAGE_INVALID_REQUEST;
+				case ERRNO_METHOD_NOT_FOUND: return ERROR_MESSAGE_METHOD_NOT_FOUND;
+				case ERRNO_INVALID_PARAMS: return ERROR_MESSAGE_INVALID_PARAMS;
+				case ERRNO_INTERNAL_ERROR: return ERROR_MESSAGE_INTERNAL_ERROR;
+				case ERRNO_SERVER_BUSY: return ERROR_MESSAGE_SERVER_BUSY;
+				case ERRNO_UNAUTHORIZED: return ERROR_MESSAGE_UNAUTHORIZED;
+				case ERRNO_FORBIDDEN: return ERROR_MESSAGE_FORBIDDEN;
+				default: return "";
+			}
+		}
+	}
+}
@@ -25,12 +25,17 @@ namespace MCP
 	static constexpr const char* MSG_KEY_PROTOCOL_VERSION = "protocolVersion";	
 	static constexpr const char* MSG_KEY_CLIENT_INFO = "clientInfo";
 	static constexpr const char* MSG_KEY_NAME = "name";
+	static constexpr const char* MSG_KEY_TITLE = "title";
 	static constexpr const char* MSG_KEY_VERSION = "version";
 	static constexpr const char* MSG_KEY_SERVER_INFO = "serverInfo";
+	static constexpr const char* MSG_KEY_INSTRUCTIONS = "instructions";
 	static constexpr const char* MSG_KEY_CAPABILITIES = "capabilities";
 	static constexpr const char* MSG_KEY_PROMPTS = "prompts";
 	static constexpr const char* MSG_KEY_RESOURCES = "resources";
 	static constexpr const char* MSG_KEY_TOOLS = "tools";
+	static constexpr const char* MSG_KEY_LOGGING = "logging";
+	static constexpr const char* MSG_KEY_LOGGER = "logger";
+	static constexpr const char* MSG_KEY_SAMPLING = "sampling";
 	static constexpr const char* MSG_KEY_LISTCHANGED = "listChanged";
 	static constexpr const char* MSG_KEY_SUBSCRIBE = "subscribe";
 	static constexpr const char* MSG_KEY_CURSOR = "cursor";
@@ -51,30 +56,46 @@ namespace MCP
 	static constexpr const char* MSG_KEY_PROGRESS = "progress";
 	static constexpr const char* MSG_KEY_TOTAL = "total";
 	static constexpr const char* MSG_KEY_REQUEST_ID = "requestId";
+	static constexpr const char* MSG_KEY_IDEMPOTENCY_KEY = "idempotencyKey";
+	static constexpr const char* MSG_KEY_SCHEMA_VERSION = "schemaVersion";
+	static constexpr const char* MSG_KEY_LOCALE = "locale";
+	static constexpr const char* MSG_KEY_TAGS = "tags";
+	static constexpr const char* MSG_KEY_CATEGORY = "category";
 	
 
 	static constexpr const char* METHOD_INITIALIZE = "initialize";
 	static constexpr const char* METHOD_NOTIFICATION_INITIALIZED = "notifications/initialized";
 	static constexpr const char* METHOD_NOTIFICATION_CANCELLED = "notifications/cancelled";
 	static constexpr const char* METHOD_NOTIFICATION_PROGRESS = "notifications/progress";
-	// Optional utilities (placeholders for future expansion per 2025-06-18 spec)
-	static constexpr const char* METHOD_NOTIFICATION_LOG = "notifications/log";
+	// Logging utility (2025-06-18 spec)
+	static constexpr const char* METHOD_NOTIFICATION_LOG = "notifications/message";
+	static constexpr const char* METHOD_LOGGING_SET_LEVEL = "logging/setLevel";
 	static constexpr const char* METHOD_PING = "ping";
 	static constexpr const char* METHOD_TOOLS_LIST = "tools/list";
 	static constexpr const char* METHOD_TOOLS_CALL = "tools/call";
 	static constexpr const char* METHOD_RESOURCES_LIST = "resources/list";
 	static constexpr const char* METHOD_RESOURCES_READ = "resources/read";
 	static constexpr const char* METHOD_PROMPTS_LIST = "prompts/list";
+	static constexpr const char* METHOD_SAMPLING_CREATE_MESSAGE = "sampling/createMessage";
 
 	static constexpr const char* CONST_TEXT = "text";
 	static constexpr const char* CONST_IMAGE = "image";
 	static constexpr const char* CONST_RESOURCE = "resource";
+	static constexpr const char* CONST_RESOURCE_LINK = "resource_link";
 
 	static constexpr const char* ERROR_MESSAGE_PARSE_ERROR = u8"parse error";
 	static constexpr const char* ERROR_MESSAGE_INVALID_REQUEST = u8"invalid request";
 	static constexpr const char* ERROR_MESSAGE_METHOD_NOT_FOUND = u8"method not found";
 	static constexpr const char* ERROR_MESSAGE_INVALID_PARAMS = u8"invalid params";
 	static constexpr const char* ERROR_MESSAGE_INTERNAL_ERROR = u8"internal error";
+	static constexpr const char* ERROR_MESSAGE_SERVER_BUSY = u8"server busy";
+	static constexpr const char* ERROR_MESSAGE_UNAUTHORIZED = u8"unauthorized";
+	static constexpr const char* ERROR_MESSAGE_FORBIDDEN = u8"forbidden";
+	static constexpr const char* ERROR_MESSAGE_UNKNOWN_TOOL = u8"unknown tool";
+	static constexpr const char* ERROR_MESSAGE_UNREGISTERED_TOOL_TASK = u8"no task registered for tool";
+	static constexpr const char* ERROR_MESSAGE_SCHEMA_CHANGED = u8"schema changed, please re-list tools";
+	static constexpr const char* ERROR_MESSAGE_IDEMPOTENCY_KEY_REUSED = u8"idempotency key already used for a different call";
+	static constexpr const char* ERROR_MESSAGE_IDEMPOTENCY_KEY_IN_FLIGHT = u8"a call with this idempotency key is still running";
 
 
 	// JSON-RPC 2.0 standard error codes
@@ -92,6 +113,7 @@ namespace MCP
 	static constexpr const int ERRNO_INTERNAL_INPUT_TERMINATE = -32003;
 	static constexpr const int ERRNO_INTERNAL_INPUT_ERROR = -32004;
 	static constexpr const int ERRNO_INTERNAL_OUTPUT_ERROR = -32005;
+	static constexpr const int ERRNO_SERVER_BUSY = -32006;
 	static constexpr const int ERRNO_SERVER_ERROR_LAST = -32099;
 
 	// Authorization related (server-defined)
@@ -142,6 +164,7 @@ namespace MCP
 		MessageType_TextContent,
 		MessageType_ImageContent,
 		MessageType_EmbeddedResource,
+		MessageType_ResourceLink,
 		MessageType_TextResourceContents,
 		MessageType_BlobResourceContents,
 		MessageType_CancelledNotification,
@@ -149,5 +172,10 @@ namespace MCP
 		MessageType_ProgressNotification,
 		MessageType_ErrorResponse,
 		MessageType_Notification_Log,
+		MessageType_Logging,
+		MessageType_SetLevelRequest,
+		MessageType_EmptyResult,
+		MessageType_CreateMessageRequest,
+		MessageType_CreateMessageResult,
 	};
 }
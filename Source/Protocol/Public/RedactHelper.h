@@ -0,0 +1,68 @@
+#pragma once
+
+#include "PublicDef.h"
+#include <string>
+#include <vector>
+#include <json/json.h>
+
+namespace MCP
+{
+	namespace RedactHelper
+	{
+		static constexpr const char* REDACTED_VALUE = "***";
+
+		inline void redact_name(Json::Value& jValue, const std::string& strName)
+		{
+			if (jValue.isObject())
+			{
+				for (const auto& strKey : jValue.getMemberNames())
+				{
+					if (strKey == strName)
+						jValue[strKey] = REDACTED_VALUE;
+					else
+						redact_name(jValue[strKey], strName);
+				}
+			}
+			else if (jValue.isArray())
+			{
+				for (Json::ArrayIndex i = 0; i < jValue.size(); ++i)
+					redact_name(jValue[i], strName);
+			}
+		}
+
+		inline void redact_path(Json::Value& jValue, const std::string& strPath)
+		{
+			Json::Value* pjValue = &jValue;
+			std::string::size_type nBegin = 0;
+			while (true)
+			{
+				auto nDot = strPath.find('.', nBegin);
+				std::string strKey = strPath.substr(nBegin, std::string::npos == nDot ? std::string::npos : nDot - nBegin);
+				if (!pjValue->isObject() || !pjValue->isMember(strKey))
+					return;
+				if (std::string::npos == nDot)
+				{
+					(*pjValue)[strKey] = REDACTED_VALUE;
+					return;
+				}
+				pjValue = &(*pjValue)[strKey];
+				nBegin = nDot + 1;
+			}
+		}
+
+		// Replaces the values of the listed fields with "***". A plain name ("pin") is redacted at
+		// any depth, a dotted path ("location.lat") only where it leads from the top-level object.
+		inline void redact_fields(Json::Value& jValue, const std::vector<std::string>& vecFields)
+		{
+			for (const auto& strField : vecFields)
+			{
+				if (strField.empty())
+					continue;
+				if (std::string::npos == strField.find('.'))
+					redact_name(jValue, strField);
+				else
+					redact_path(jValue, strField);
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@
 #include <string>
 #include <codecvt>
 #include <locale>
+#include <functional>
 
 namespace MCP
 {
@@ -33,5 +34,35 @@ namespace MCP
 				return "";
 			}
 		}
+
+		// Replaces each ${NAME} in str with the value produced by fnResolve.
+		// Names that fnResolve does not know, and unterminated references, are kept literally.
+		inline std::string expand_variables(const std::string& str, const std::function<bool(const std::string&, std::string&)>& fnResolve)
+		{
+			std::string strResult;
+			std::string::size_type nPos = 0;
+			while (nPos < str.size())
+			{
+				auto nBegin = str.find("${", nPos);
+				if (std::string::npos == nBegin)
+					break;
+				auto nEnd = str.find('}', nBegin + 2);
+				if (std::string::npos == nEnd)
+					break;
+
+				strResult.append(str, nPos, nBegin - nPos);
+				std::string strName = str.substr(nBegin + 2, nEnd - nBegin - 2);
+				std::string strValue;
+				if (!strName.empty() && fnResolve && fnResolve(strName, strValue))
+					strResult += strValue;
+				else
+					strResult.append(str, nBegin, nEnd - nBegin + 1);
+				nPos = nEnd + 1;
+			}
+			if (nPos < str.size())
+				strResult.append(str, nPos, std::string::npos);
+
+			return strResult;
+		}
 	}
 }
\ No newline at end of file
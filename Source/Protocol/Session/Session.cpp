@@ -1,6 +1,9 @@
 #include "Session.h"
 #include "../Public/PublicDef.h"
 #include "../Public/Config.h"
+#include "../Public/StringHelper.h"
+#include "../Public/ErrorHelper.h"
+#include "../Public/RedactHelper.h"
 #include "../Message/BasicMessage.h"
 #include "../Message/Notification.h"
 #include "../Message/Request.h"
@@ -9,6 +12,15 @@
 
 #include <memory>
 #include <algorithm>
+#include <cctype>
+#include <cmath>
+#include <cstdlib>
+#include <fstream>
+#include <sstream>
+#include <ctime>
+#include <functional>
+#include <iomanip>
+#include <iostream>
 #include <json/json.h>
 
 namespace MCP
@@ -25,11 +37,19 @@ namespace MCP
 		if (!m_spTransport)
 			return ERRNO_INTERNAL_ERROR;
 
+		// A session that was terminated can be made ready again for the next client
+		if (SessionState_Shut == m_eSessionState)
+			m_eSessionState = SessionState_Original;
+
 		int iErrCode = ERRNO_OK;
 		iErrCode = m_spTransport->Connect();
 		if (ERRNO_OK != iErrCode)
 			return iErrCode;
 
+		m_bInitializeTimedOut = false;
+		if (m_nInitializeTimeoutMs > 0)
+			iErrCode = StartInitializeWatchdog();
+
 		return iErrCode;
 	}
 
@@ -44,11 +64,15 @@ namespace MCP
 		{
 			std::string strIncomingMsg;
 			iErrCode = m_spTransport->Read(strIncomingMsg);
+			if (ERRNO_OK == iErrCode && m_bInitializeTimedOut)
+			{
+				// The client missed the initialize window, whatever it sends now is not processed
+				iErrCode = ERRNO_INTERNAL_INPUT_TERMINATE;
+				break;
+			}
 			if (ERRNO_OK == iErrCode)
 			{
-				std::shared_ptr<MCP::Message> spMsg;
-				iErrCode = ParseMessage(strIncomingMsg, spMsg);
-				iErrCode = ProcessMessage(iErrCode, spMsg);
+				iErrCode = Dispatch(strIncomingMsg);
 			}
 			else
 			{
@@ -59,11 +83,59 @@ namespace MCP
 		return iErrCode;
 	}
 
+	int CMCPSession::Dispatch(const std::string& strIncomingMsg)
+	{
+		std::shared_ptr<MCP::Message> spMsg;
+		int iErrCode = ParseMessage(strIncomingMsg, spMsg);
+
+		return ProcessMessage(iErrCode, spMsg);
+	}
+
 	int CMCPSession::Terminate()
 	{
+		SwitchState(SessionState_Shutting);
+		StopInitializeWatchdog();
+
+		// Tool tasks waiting on the client are released first, nobody will read its reply anymore
+		{
+			const std::lock_guard<std::mutex> _lock(m_mtxPendingRequests);
+			for (auto& pending : m_hashPendingRequests)
+			{
+				pending.second->bDone = true;
+				pending.second->iErrCode = ERRNO_INTERNAL_ERROR;
+			}
+		}
+		m_cvPendingRequests.notify_all();
+
+		// The async thread cancels the tasks still running before it exits, anything
+		// queued or cached afterwards belongs to this session and is dropped
 		StopAsyncTaskThread();
 		if (m_upTaskThread && m_upTaskThread->joinable())
 			m_upTaskThread->join();
+		m_upTaskThread.reset();
+		{
+			const std::lock_guard<std::mutex> _lock(m_mtxAsyncThread);
+			m_deqAsyncTasks.clear();
+			m_vecCancelledTaskIds.clear();
+		}
+		m_vecAsyncTasksCache.clear();
+		m_deqWaitingTasks.clear();
+
+		// What the client set up or left behind does not carry over to the next one
+		{
+			const std::lock_guard<std::mutex> _lock(m_mtxPendingRequests);
+			m_hashPendingRequests.clear();
+		}
+		{
+			const std::lock_guard<std::mutex> _lock(m_mtxIdempotency);
+			m_hashIdempotentResults.clear();
+		}
+		m_iLogLevel = 1;
+		m_clientInfo = MCP::Implementation();
+		m_jClientCapabilities = Json::Value(Json::objectValue);
+		m_strClientLocale.clear();
+		m_hashMessage.clear();
+		SwitchState(SessionState_Shut);
 
 		if (!m_spTransport)
 			return ERRNO_INTERNAL_ERROR;
@@ -105,6 +177,7 @@ namespace MCP
 	{
 		std::shared_ptr<MCP::Request> spRequest{ nullptr };
 		std::string strMessage;
+		bool bIdempotencyReserved = false;
 
 		if (ERRNO_OK != iErrCode)
 		{
@@ -129,6 +202,11 @@ namespace MCP
     {
         goto PROC_END;
     }
+		if (IsServerMethodDisabled(spRequest->strMethod))
+		{
+			iErrCode = ERRNO_METHOD_NOT_FOUND;
+			goto PROC_END;
+		}
 
 		switch (spRequest->eMessageType)
 		{
@@ -152,8 +230,22 @@ namespace MCP
 				{
 					goto PROC_END;
 				}
+				{
+					auto spInitializeRequest = std::dynamic_pointer_cast<MCP::InitializeRequest>(spRequest);
+					if (spInitializeRequest)
+					{
+						m_clientInfo = spInitializeRequest->clientInfo;
+						m_jClientCapabilities = spInitializeRequest->jCapabilities;
+						m_strClientLocale = spInitializeRequest->strLocale;
+					}
+				}
 
 				iErrCode = SwitchState(SessionState_Initializing);
+				{
+					const std::lock_guard<std::mutex> _lock(m_mtxInitializeWatchdog);
+					m_bInitializeReceived = true;
+				}
+				m_cvInitializeWatchdog.notify_all();
 
 			} break;
 			case MessageType_ListToolsRequest:
@@ -213,6 +305,7 @@ namespace MCP
 			} break;
 			case MessageType_CallToolRequest:
 			{
+				auto tpReceived = std::chrono::steady_clock::now();
 				if (CMCPSession::SessionState_Initialized != CMCPSession::GetInstance().GetSessionState())
 				{
 					iErrCode = ERRNO_INVALID_REQUEST;
@@ -225,13 +318,49 @@ namespace MCP
 					iErrCode = ERRNO_INTERNAL_ERROR;
 					goto PROC_END;
 				}
-				auto spProcessCallToolRequest = CMCPSession::GetInstance().GetServerCallToolsTask(spCallToolRequest->strName);
-				if (!spProcessCallToolRequest)
+				// A tool the client was never offered is a bad argument unless a fallback task takes it,
+				// an offered tool without a task is a server wiring error
+				bool bKnownTool = nullptr != FindServerTool(spCallToolRequest->strName);
+				if (!bKnownTool && !m_spFallbackCallToolTask)
 				{
-					strMessage = ERROR_MESSAGE_INVALID_PARAMS;
+					strMessage = std::string(ERROR_MESSAGE_UNKNOWN_TOOL) + u8": " + spCallToolRequest->strName;
 					iErrCode = ERRNO_INVALID_PARAMS;
 					goto PROC_END;
 				}
+				iErrCode = CheckToolSchemaVersion(spCallToolRequest, strMessage);
+				if (ERRNO_OK != iErrCode)
+					goto PROC_END;
+				auto spProcessCallToolRequest = bKnownTool ? CMCPSession::GetInstance().GetServerCallToolsTask(spCallToolRequest->strName) : m_spFallbackCallToolTask;
+				if (!spProcessCallToolRequest)
+				{
+					strMessage = std::string(ERROR_MESSAGE_UNREGISTERED_TOOL_TASK) + u8": " + spCallToolRequest->strName;
+					iErrCode = ERRNO_INTERNAL_ERROR;
+					goto PROC_END;
+				}
+				iErrCode = CheckToolArgumentLimits(spCallToolRequest->jArguments, strMessage);
+				if (ERRNO_OK != iErrCode)
+					goto PROC_END;
+				if (m_bArgumentCoercion)
+				{
+					iErrCode = CoerceToolArguments(spCallToolRequest->strName, spCallToolRequest->jArguments, strMessage);
+					if (ERRNO_OK != iErrCode)
+						goto PROC_END;
+				}
+				iErrCode = ApplyToolArgumentDefaults(spCallToolRequest->strName, spCallToolRequest->jArguments, strMessage);
+				if (ERRNO_OK != iErrCode)
+					goto PROC_END;
+				iErrCode = CheckToolArgumentTypes(spCallToolRequest->strName, spCallToolRequest->jArguments, strMessage);
+				if (ERRNO_OK != iErrCode)
+					goto PROC_END;
+				// Retries are matched on the arguments as the tool would receive them
+				{
+					bool bReplayed = false;
+					iErrCode = BeginIdempotentCall(spCallToolRequest, bReplayed, bIdempotencyReserved, strMessage);
+					if (bReplayed)
+						AuditToolCall(spRequest, u8"replayed");
+					if (ERRNO_OK != iErrCode || bReplayed)
+						goto PROC_END;
+				}
 				auto spNewTask = spProcessCallToolRequest->Clone();
 				if (!spNewTask)
 				{
@@ -245,6 +374,7 @@ namespace MCP
 					goto PROC_END;
 				}
 				spNewProcessCallToolRequest->SetRequest(spRequest);
+				spNewProcessCallToolRequest->MarkQueued(tpReceived);
 				iErrCode = CommitAsyncTask(spNewProcessCallToolRequest);
 
 			} break;
@@ -274,12 +404,61 @@ namespace MCP
 						return ERRNO_INTERNAL_ERROR;
 				}
 
+			} break;
+			case MessageType_SetLevelRequest:
+			{
+				if (CMCPSession::SessionState_Initialized != CMCPSession::GetInstance().GetSessionState())
+				{
+					iErrCode = ERRNO_INVALID_REQUEST;
+					goto PROC_END;
+				}
+				if (!GetServerCapabilities().logging.bExist)
+				{
+					iErrCode = ERRNO_METHOD_NOT_FOUND;
+					goto PROC_END;
+				}
+
+				auto spSetLevelRequest = std::dynamic_pointer_cast<MCP::SetLevelRequest>(spRequest);
+				if (!spSetLevelRequest)
+				{
+					iErrCode = ERRNO_INTERNAL_ERROR;
+					goto PROC_END;
+				}
+				int iLevel = GetLogLevelRank(spSetLevelRequest->strLevel);
+				if (iLevel < 0)
+				{
+					iErrCode = ERRNO_INVALID_PARAMS;
+					goto PROC_END;
+				}
+				m_iLogLevel = iLevel;
+
+				auto spResult = std::make_shared<MCP::EmptyResult>(true);
+				if (!spResult)
+				{
+					iErrCode = ERRNO_INTERNAL_ERROR;
+					goto PROC_END;
+				}
+				spResult->requestId = spRequest->requestId;
+				{
+					std::string strResponse;
+					if (ERRNO_OK != spResult->Serialize(strResponse))
+						return ERRNO_INTERNAL_ERROR;
+					if (!m_spTransport || ERRNO_OK != m_spTransport->Write(strResponse))
+						return ERRNO_INTERNAL_ERROR;
+				}
+
 			} break;
 			default: break;
 		}
 
 	PROC_END:
+		if (ERRNO_OK != iErrCode)
 		{
+			if (spRequest && MessageType_CallToolRequest == spRequest->eMessageType)
+				AuditToolCall(spRequest, u8"rejected", iErrCode, strMessage);
+			if (bIdempotencyReserved)
+				ReleaseIdempotencyKey(spRequest);
+
 			auto spTask = std::make_shared<ProcessErrorRequest>(spRequest);
 			if (spTask)
 			{
@@ -301,7 +480,37 @@ namespace MCP
 			return ERRNO_INTERNAL_ERROR;
 		m_hashMessage[MessageCategory_Response].push_back(spMsg);
 
-		return ERRNO_INTERNAL_ERROR;
+		if (DataType_String != spResponse->requestId.eIdDataType)
+			return ERRNO_INVALID_RESPONSE;
+
+		std::shared_ptr<PendingRequest> spPending;
+		{
+			const std::lock_guard<std::mutex> _lock(m_mtxPendingRequests);
+			auto itr = m_hashPendingRequests.find(spResponse->requestId.strId);
+			if (itr == m_hashPendingRequests.end())
+				return ERRNO_INVALID_RESPONSE;
+			spPending = itr->second;
+
+			auto spErrorResponse = std::dynamic_pointer_cast<MCP::ErrorResponse>(spResponse);
+			auto spCreateMessageResult = std::dynamic_pointer_cast<MCP::CreateMessageResult>(spResponse);
+			if (spErrorResponse)
+			{
+				spPending->iErrCode = spErrorResponse->iCode;
+				spPending->jResult = Json::Value(spErrorResponse->strMesage);
+			}
+			else if (spCreateMessageResult)
+			{
+				spPending->jResult = spCreateMessageResult->jResult;
+			}
+			else
+			{
+				spPending->iErrCode = ERRNO_INVALID_RESPONSE;
+			}
+			spPending->bDone = true;
+		}
+		m_cvPendingRequests.notify_all();
+
+		return ERRNO_OK;
 	}
 
 	int CMCPSession::ProcessNotification(int iErrCode, const std::shared_ptr<MCP::Message>& spMsg)
@@ -382,15 +591,31 @@ namespace MCP
 		{
 			case MessageCategory_Request:
 			{
-				return ParseRequest(strMsg, spMsg);
+				std::string strMessage;
+				int iErrCode = m_bStrictParsing ? CheckKnownRequestFields(jVal, strMessage) : ERRNO_OK;
+				if (ERRNO_OK == iErrCode)
+					iErrCode = ParseRequest(jVal, spMsg);
+				if (ERRNO_OK != iErrCode)
+				{
+					// The request could not be turned into a message, but it still deserves an answer
+					auto spRequest = std::make_shared<MCP::Request>(MessageType_Unknown, false);
+					auto spTask = std::make_shared<ProcessErrorRequest>(spRequest);
+					if (spRequest && spTask && ERRNO_OK == spRequest->requestId.DoDeserialize(jVal))
+					{
+						spTask->SetErrorCode(iErrCode);
+						spTask->SetErrorMessage(strMessage);
+						spTask->Execute();
+					}
+				}
+				return iErrCode;
 			} break;
 			case MessageCategory_Response:
 			{
-				return ParseResponse(strMsg, spMsg);
+				return ParseResponse(jVal, spMsg);
 			} break;
 			case MessageCategory_Notification:
 			{
-				return ParseNotification(strMsg, spMsg);
+				return ParseNotification(jVal, spMsg);
 			} break;
 			default: break;
 		}
@@ -398,14 +623,14 @@ namespace MCP
 		return ERRNO_INTERNAL_ERROR;
 	}
 
-	int CMCPSession::ParseRequest(const std::string& strMsg, std::shared_ptr<MCP::Message>& spMsg)
+	int CMCPSession::ParseRequest(const Json::Value& jMsg, std::shared_ptr<MCP::Message>& spMsg)
 	{
 		auto spRequest = std::make_shared<MCP::Request>(MessageType_Unknown, false);
 		if (!spRequest)
 			return ERRNO_PARSE_ERROR;
 
 		int iErrCode = ERRNO_OK;
-		iErrCode = spRequest->Deserialize(strMsg);
+		iErrCode = spRequest->DoDeserialize(jMsg);
 		if (ERRNO_OK != iErrCode)
 			return iErrCode;
 		if (!spRequest->IsValid())
@@ -417,7 +642,7 @@ namespace MCP
 			if (!spInitializeRequest)
 				return ERRNO_PARSE_ERROR;
 
-			iErrCode = spInitializeRequest->Deserialize(strMsg);
+			iErrCode = spInitializeRequest->DoDeserialize(jMsg);
 			if (ERRNO_OK != iErrCode)
 				return ERRNO_INVALID_REQUEST;
 
@@ -431,7 +656,7 @@ namespace MCP
 			if (!spListToolsRequest)
 				return ERRNO_PARSE_ERROR;
 
-			iErrCode = spListToolsRequest->Deserialize(strMsg);
+			iErrCode = spListToolsRequest->DoDeserialize(jMsg);
 			if (ERRNO_OK != iErrCode)
 				return ERRNO_INVALID_REQUEST;
 
@@ -445,7 +670,7 @@ namespace MCP
 			if (!spCallToolRequest)
 				return ERRNO_PARSE_ERROR;
 
-			iErrCode = spCallToolRequest->Deserialize(strMsg);
+			iErrCode = spCallToolRequest->DoDeserialize(jMsg);
 			if (ERRNO_OK != iErrCode)
 				return ERRNO_INVALID_REQUEST;
 
@@ -459,7 +684,7 @@ namespace MCP
 			if (!spPingRequest)
 				return ERRNO_PARSE_ERROR;
 
-			iErrCode = spPingRequest->Deserialize(strMsg);
+			iErrCode = spPingRequest->DoDeserialize(jMsg);
 			if (ERRNO_OK != iErrCode)
 				return ERRNO_INVALID_REQUEST;
 
@@ -467,13 +692,27 @@ namespace MCP
 
 			return ERRNO_OK;
 		}
+		else if (spRequest->strMethod.compare(METHOD_LOGGING_SET_LEVEL) == 0)
+		{
+			auto spSetLevelRequest = std::make_shared<MCP::SetLevelRequest>(true);
+			if (!spSetLevelRequest)
+				return ERRNO_PARSE_ERROR;
+
+			iErrCode = spSetLevelRequest->DoDeserialize(jMsg);
+			if (ERRNO_OK != iErrCode)
+				return ERRNO_INVALID_REQUEST;
+
+			spMsg = spSetLevelRequest;
+
+			return ERRNO_OK;
+		}
 		else if (spRequest->strMethod.compare(METHOD_RESOURCES_LIST) == 0)
 		{
 			auto spListResourcesRequest = std::make_shared<MCP::ListResourcesRequest>(true);
 			if (!spListResourcesRequest)
 				return ERRNO_PARSE_ERROR;
 
-			iErrCode = spListResourcesRequest->Deserialize(strMsg);
+			iErrCode = spListResourcesRequest->DoDeserialize(jMsg);
 			if (ERRNO_OK != iErrCode)
 				return ERRNO_INVALID_REQUEST;
 
@@ -487,7 +726,7 @@ namespace MCP
 			if (!spReadResourceRequest)
 				return ERRNO_PARSE_ERROR;
 
-			iErrCode = spReadResourceRequest->Deserialize(strMsg);
+			iErrCode = spReadResourceRequest->DoDeserialize(jMsg);
 			if (ERRNO_OK != iErrCode)
 				return ERRNO_INVALID_REQUEST;
 
@@ -501,7 +740,7 @@ namespace MCP
 			if (!spListPromptsRequest)
 				return ERRNO_PARSE_ERROR;
 
-			iErrCode = spListPromptsRequest->Deserialize(strMsg);
+			iErrCode = spListPromptsRequest->DoDeserialize(jMsg);
 			if (ERRNO_OK != iErrCode)
 				return ERRNO_INVALID_REQUEST;
 
@@ -510,22 +749,85 @@ namespace MCP
 			return ERRNO_OK;
 		}
 
-		return ERRNO_INTERNAL_ERROR;
+		return ERRNO_METHOD_NOT_FOUND;
 	}
 
-	int CMCPSession::ParseResponse(const std::string& strMsg, std::shared_ptr<MCP::Message>& spMsg)
+	int CMCPSession::ParseResponse(const Json::Value& jMsg, std::shared_ptr<MCP::Message>& spMsg)
 	{
-		return ERRNO_INTERNAL_ERROR;
+		// The only requests the server sends are sampling/createMessage, so any result is one of theirs
+		if (jMsg.isMember(MSG_KEY_ERROR))
+		{
+			auto spErrorResponse = std::make_shared<MCP::ErrorResponse>(false);
+			if (!spErrorResponse)
+				return ERRNO_INTERNAL_ERROR;
+			int iErrCode = spErrorResponse->DoDeserialize(jMsg);
+			if (ERRNO_OK != iErrCode)
+				return iErrCode;
+			spMsg = spErrorResponse;
+
+			return ERRNO_OK;
+		}
+
+		auto spCreateMessageResult = std::make_shared<MCP::CreateMessageResult>(false);
+		if (!spCreateMessageResult)
+			return ERRNO_INTERNAL_ERROR;
+		int iErrCode = spCreateMessageResult->DoDeserialize(jMsg);
+		if (ERRNO_OK != iErrCode)
+			return iErrCode;
+		spMsg = spCreateMessageResult;
+
+		return ERRNO_OK;
+	}
+
+	int CMCPSession::CheckKnownRequestFields(const Json::Value& jMsg, std::string& strMessage)
+	{
+		static const std::unordered_map<std::string, std::vector<std::string>> s_hashParamsFields = {
+			{ METHOD_INITIALIZE, { MSG_KEY_PROTOCOL_VERSION, MSG_KEY_CAPABILITIES, MSG_KEY_CLIENT_INFO } },
+			{ METHOD_PING, {} },
+			{ METHOD_TOOLS_LIST, { MSG_KEY_CURSOR } },
+			{ METHOD_TOOLS_CALL, { MSG_KEY_NAME, MSG_KEY_ARGUMENTS } },
+			{ METHOD_LOGGING_SET_LEVEL, { MSG_KEY_LEVEL } },
+			{ METHOD_RESOURCES_LIST, { MSG_KEY_CURSOR } },
+			{ METHOD_RESOURCES_READ, { MSG_KEY_URI } },
+			{ METHOD_PROMPTS_LIST, { MSG_KEY_CURSOR } },
+		};
+
+		auto fnCheck = [&strMessage](const Json::Value& jObject, const std::vector<std::string>& vecFields, const char* lpcszWhere) -> int
+		{
+			for (const auto& strKey : jObject.getMemberNames())
+			{
+				if (std::find(vecFields.begin(), vecFields.end(), strKey) == vecFields.end())
+				{
+					strMessage = std::string(ERROR_MESSAGE_INVALID_PARAMS) + u8": unexpected field '" + strKey + u8"' in " + lpcszWhere;
+					return ERRNO_INVALID_PARAMS;
+				}
+			}
+			return ERRNO_OK;
+		};
+
+		int iErrCode = fnCheck(jMsg, { MSG_KEY_JSONRPC, MSG_KEY_ID, MSG_KEY_METHOD, MSG_KEY_PARAMS }, u8"request");
+		if (ERRNO_OK != iErrCode)
+			return iErrCode;
+
+		if (!jMsg[MSG_KEY_METHOD].isString() || !jMsg.isMember(MSG_KEY_PARAMS) || !jMsg[MSG_KEY_PARAMS].isObject())
+			return ERRNO_OK;
+		auto itrFound = s_hashParamsFields.find(jMsg[MSG_KEY_METHOD].asString());
+		if (itrFound == s_hashParamsFields.end())
+			return ERRNO_OK;
+		auto vecFields = itrFound->second;
+		vecFields.push_back(MSG_KEY_META);
+
+		return fnCheck(jMsg[MSG_KEY_PARAMS], vecFields, u8"params");
 	}
 
-	int CMCPSession::ParseNotification(const std::string& strMsg, std::shared_ptr<MCP::Message>& spMsg)
+	int CMCPSession::ParseNotification(const Json::Value& jMsg, std::shared_ptr<MCP::Message>& spMsg)
 	{
 		auto spNotification = std::make_shared<MCP::Notification>(MessageType_Unknown, false);
 		if (!spNotification)
 			return ERRNO_PARSE_ERROR;
 
 		int iErrCode = ERRNO_OK;
-		iErrCode = spNotification->Deserialize(strMsg);
+		iErrCode = spNotification->DoDeserialize(jMsg);
 		if (ERRNO_OK != iErrCode)
 			return iErrCode;
 		if (!spNotification->IsValid())
@@ -537,7 +839,7 @@ namespace MCP
 			if (!spInitializedNotification)
 				return ERRNO_PARSE_ERROR;
 
-			iErrCode = spInitializedNotification->Deserialize(strMsg);
+			iErrCode = spInitializedNotification->DoDeserialize(jMsg);
 			if (ERRNO_OK != iErrCode)
 				return ERRNO_INVALID_NOTIFICATION;
 
@@ -551,7 +853,7 @@ namespace MCP
 			if (!spCancelledNotification)
 				return ERRNO_PARSE_ERROR;
 
-			iErrCode = spCancelledNotification->Deserialize(strMsg);
+			iErrCode = spCancelledNotification->DoDeserialize(jMsg);
 			if (ERRNO_OK != iErrCode)
 				return ERRNO_INVALID_NOTIFICATION;
 
@@ -563,109 +865,1067 @@ namespace MCP
 		return ERRNO_INTERNAL_ERROR;
 	}
 
-	void CMCPSession::SetTransport(const std::shared_ptr<CMCPTransport>& spTransport)
+	int CMCPSession::GetLogLevelRank(const std::string& strLevel)
 	{
-		m_spTransport = spTransport;
+		// Syslog severities as listed by the spec, from least to most severe
+		static const char* s_arrLevels[] = { "debug", "info", "notice", "warning", "error", "critical", "alert", "emergency" };
+		for (int i = 0; i < static_cast<int>(sizeof(s_arrLevels) / sizeof(s_arrLevels[0])); ++i)
+		{
+			if (strLevel.compare(s_arrLevels[i]) == 0)
+				return i;
+		}
+
+		return -1;
 	}
 
-	void CMCPSession::SetServerInfo(const MCP::Implementation& impl)
+	int CMCPSession::Log(const std::string& strLevel, const std::string& strText, const std::string& strLogger)
 	{
-		m_serverInfo = impl;
+		int iLevel = GetLogLevelRank(strLevel);
+		if (iLevel < 0)
+			return ERRNO_INVALID_PARAMS;
+
+		// Messages are only sent at or above the client's level, the initialized check is left to WriteNotification
+		if (!GetServerCapabilities().logging.bExist || iLevel < m_iLogLevel)
+			return ERRNO_OK;
+
+		MCP::LogNotification logNotification(false);
+		logNotification.strMethod = METHOD_NOTIFICATION_LOG;
+		logNotification.strLevel = strLevel;
+		logNotification.strLogger = strLogger;
+		logNotification.strText = strText;
+
+		return WriteNotification(logNotification);
 	}
 
-	void CMCPSession::SetServerCapabilities(const MCP::ServerCapabilities& capabilities)
+	int CMCPSession::WriteNotification(const MCP::Notification& notification)
 	{
-		m_capabilities = capabilities;
+		std::string strNotification;
+		if (ERRNO_OK != notification.Serialize(strNotification))
+			return ERRNO_INTERNAL_ERROR;
+
+		// A notification sent before the client finished initializing is not delivered, it could not tell it apart
+		if (SessionState_Initialized != m_eSessionState)
+		{
+			DeadLetterNotification(notification.strMethod, strNotification, u8"session not initialized");
+			return ERRNO_OK;
+		}
+		if (!m_spTransport)
+		{
+			DeadLetterNotification(notification.strMethod, strNotification, u8"no transport");
+			return ERRNO_INTERNAL_ERROR;
+		}
+
+		int iErrCode = m_spTransport->Write(strNotification);
+		if (ERRNO_OK != iErrCode)
+			DeadLetterNotification(notification.strMethod, strNotification, u8"write failed (" + std::to_string(iErrCode) + u8")");
+
+		return iErrCode;
 	}
 
-	void CMCPSession::SetServerToolsPagination(bool bPagination)
+	void CMCPSession::DeadLetterNotification(const std::string& strMethod, const std::string& strNotification, const std::string& strReason)
 	{
-		m_bToolsPagination = bPagination;
+		if (!m_spDeadLetterLogger)
+		{
+			if (m_spTransport)
+				m_spTransport->Error(u8"undeliverable notification " + strMethod + u8": " + strReason);
+			return;
+		}
+
+		Json::Value jEntry(Json::objectValue);
+		jEntry["time"] = GetUtcTimestamp();
+		jEntry["client"] = m_clientInfo.strName;
+		jEntry[MSG_KEY_METHOD] = strMethod;
+		Json::Value jNotification;
+		Json::Reader reader;
+		if (reader.parse(strNotification, jNotification))
+			jEntry["notification"] = jNotification;
+		jEntry["reason"] = strReason;
+
+		m_spDeadLetterLogger->Record(jEntry);
 	}
 
-	void CMCPSession::SetServerTools(const std::vector<MCP::Tool>& tools)
+	std::string CMCPSession::GetUtcTimestamp()
 	{
-		m_tools = tools;
+		// std::gmtime returns a shared buffer
+		const std::lock_guard<std::mutex> _lock(m_mtxAudit);
+		std::time_t tNow = std::chrono::system_clock::to_time_t(std::chrono::system_clock::now());
+		std::ostringstream oss;
+		oss << std::put_time(std::gmtime(&tNow), "%Y-%m-%dT%H:%M:%SZ");
+
+		return oss.str();
 	}
 
-	void CMCPSession::SetServerCallToolsTasks(const std::unordered_map<std::string, std::shared_ptr<MCP::ProcessCallToolRequest>>& hashCallToolsTasks)
+	int CMCPSession::RequestSampling(const Json::Value& jParams, Json::Value& jResult, unsigned int nTimeoutMs)
 	{
-		m_hashCallToolsTasks = hashCallToolsTasks;
+		if (SessionState_Initialized != m_eSessionState)
+			return ERRNO_INTERNAL_ERROR;
+		if (!m_jClientCapabilities.isMember(MSG_KEY_SAMPLING))
+			return ERRNO_METHOD_NOT_FOUND;
+		if (!m_spTransport)
+			return ERRNO_INTERNAL_ERROR;
+
+		MCP::CreateMessageRequest request(false);
+		request.requestId.eIdDataType = DataType_String;
+		request.requestId.strId = "tinymcp-" + std::to_string(++m_nOutgoingRequestId);
+		request.jParams = jParams;
+
+		std::string strRequest;
+		if (ERRNO_OK != request.Serialize(strRequest))
+			return ERRNO_INVALID_PARAMS;
+
+		auto spPending = std::make_shared<PendingRequest>();
+		{
+			const std::lock_guard<std::mutex> _lock(m_mtxPendingRequests);
+			m_hashPendingRequests[request.requestId.strId] = spPending;
+		}
+
+		int iErrCode = m_spTransport->Write(strRequest);
+		if (ERRNO_OK == iErrCode)
+		{
+			std::unique_lock<std::mutex> _lock(m_mtxPendingRequests);
+			if (!m_cvPendingRequests.wait_for(_lock, std::chrono::milliseconds(nTimeoutMs), [&spPending]() { return spPending->bDone; }))
+				iErrCode = ERRNO_INTERNAL_ERROR;
+			else
+				iErrCode = spPending->iErrCode;
+		}
+
+		{
+			const std::lock_guard<std::mutex> _lock(m_mtxPendingRequests);
+			m_hashPendingRequests.erase(request.requestId.strId);
+		}
+
+		if (ERRNO_OK == iErrCode)
+			jResult = spPending->jResult;
+
+		return iErrCode;
 	}
 
-	MCP::Implementation CMCPSession::GetServerInfo() const
+	void CMCPSession::SetTransport(const std::shared_ptr<CMCPTransport>& spTransport)
 	{
-		return m_serverInfo;
+		m_spTransport = spTransport;
 	}
 
-	MCP::ServerCapabilities CMCPSession::GetServerCapabilities() const
+	void CMCPSession::SetServerInfo(const MCP::Implementation& impl)
 	{
-		return m_capabilities;
+		m_serverInfo = impl;
 	}
 
-	bool CMCPSession::GetServerToolsPagination() const
+	void CMCPSession::SetServerCapabilities(const MCP::ServerCapabilities& capabilities)
 	{
-		return m_bToolsPagination;
+		m_capabilities = capabilities;
 	}
 
-	std::vector<MCP::Tool> CMCPSession::GetServerTools() const
+	void CMCPSession::SetServerInstructions(const std::string& strInstructions)
 	{
-		return m_tools;
+		m_strInstructions = strInstructions;
 	}
 
-	std::shared_ptr<CMCPTransport> CMCPSession::GetTransport() const
+	void CMCPSession::SetServerClientInstructions(const std::string& strClientName, const std::string& strInstructions)
 	{
-		return m_spTransport;
+		m_hashClientInstructions[strClientName] = strInstructions;
 	}
 
-	int CMCPSession::SwitchState(SessionState eState)
+	void CMCPSession::SetServerInstructionsFile(const std::string& strPath)
 	{
-		if (SessionState_Initializing == eState)
-		{
-			if (SessionState_Original != m_eSessionState)
-				return ERRNO_INTERNAL_ERROR;
-		}
-
-		if (SessionState_Initialized == eState)
-		{
-			if (SessionState_Initializing != m_eSessionState)
-				return ERRNO_INTERNAL_ERROR;
-		}
-
-		m_eSessionState = eState;
-
-		return ERRNO_OK;
+		m_strInstructionsFile = strPath;
 	}
 
-	CMCPSession::SessionState CMCPSession::GetSessionState() const
+	void CMCPSession::SetServerToolsPagination(bool bPagination)
 	{
-		return m_eSessionState;
+		m_bToolsPagination = bPagination;
 	}
 
-	std::shared_ptr<MCP::ProcessRequest> CMCPSession::GetServerCallToolsTask(const std::string& strToolName)
+	void CMCPSession::SetServerDuplicateToolPolicy(DuplicateToolPolicy ePolicy)
 	{
-		if (m_hashCallToolsTasks.count(strToolName) > 0)
-			return m_hashCallToolsTasks[strToolName];
-
-		return nullptr;
+		m_eDuplicateToolPolicy = ePolicy;
 	}
 
-	int CMCPSession::CommitAsyncTask(const std::shared_ptr<MCP::CMCPTask>& spTask)
+	int CMCPSession::SetServerTools(const std::vector<MCP::Tool>& tools, std::vector<std::pair<std::size_t, std::string>>* pvecRenamed)
 	{
-		if (!spTask)
-			return ERRNO_INTERNAL_ERROR;
-
-		if (m_bRunAsyncTask) 
+		int iErrCode = ERRNO_OK;
+		std::vector<MCP::Tool> vecTools;
+		std::vector<std::pair<std::size_t, std::string>> vecRenamed;
+		// Name -> position in vecTools and index it was given at
+		std::unordered_map<std::string, std::pair<std::size_t, std::size_t>> hashRegistered;
+		std::unordered_map<std::string, bool> hashGivenNames;
+		for (const auto& tool : tools)
+			hashGivenNames[tool.strName] = true;
+
+		for (std::size_t i = 0; i < tools.size(); ++i)
 		{
-			std::unique_lock<std::mutex> _lock(m_mtxAsyncThread);
-
-			if (!m_bRunAsyncTask)
+			auto tool = tools[i];
+			auto itrRegistered = hashRegistered.find(tool.strName);
+			if (itrRegistered == hashRegistered.end())
 			{
-				_lock.unlock();
-				return ERRNO_OK;
+				hashRegistered[tool.strName] = std::make_pair(vecTools.size(), i);
+				vecTools.push_back(tool);
+				continue;
 			}
 
-			m_deqAsyncTasks.push_back(spTask);
+			std::string strWarning = std::string(MSG_KEY_TOOLS) + u8"[" + std::to_string(i) + u8"] " + tool.strName
+				+ u8": duplicate name, first registered as " + std::string(MSG_KEY_TOOLS) + u8"[" + std::to_string(itrRegistered->second.second) + u8"]";
+			switch (m_eDuplicateToolPolicy)
+			{
+				case DuplicateToolPolicy_Error:
+				{
+					strWarning += u8", no tools registered";
+					iErrCode = ERRNO_INVALID_PARAMS;
+				} break;
+				case DuplicateToolPolicy_LastWins:
+				{
+					strWarning += u8", replaced";
+					vecTools[itrRegistered->second.first] = tool;
+				} break;
+				case DuplicateToolPolicy_FirstWins:
+				{
+					strWarning += u8", ignored";
+				} break;
+				case DuplicateToolPolicy_Suffix:
+				{
+					// Names given by the caller are never taken over, a later tool may be registered under them
+					std::string strName;
+					for (unsigned int nSuffix = 2; strName.empty() || hashRegistered.count(strName) > 0 || hashGivenNames.count(strName) > 0; ++nSuffix)
+						strName = tool.strName + u8"_" + std::to_string(nSuffix);
+					strWarning += u8", registered as " + strName;
+					tool.strName = strName;
+					vecRenamed.push_back(std::make_pair(i, strName));
+					hashRegistered[strName] = std::make_pair(vecTools.size(), i);
+					vecTools.push_back(tool);
+				} break;
+				default:
+				{
+					strWarning += u8", both kept";
+					vecTools.push_back(tool);
+				} break;
+			}
+
+			if (m_spTransport)
+				m_spTransport->Error(strWarning);
+			else
+				std::cerr << strWarning << std::endl;
+		}
+
+		if (ERRNO_OK != iErrCode)
+			return iErrCode;
+		m_tools = vecTools;
+		if (pvecRenamed)
+			*pvecRenamed = vecRenamed;
+
+		return ERRNO_OK;
+	}
+
+	void CMCPSession::SetServerFallbackCallToolTask(const std::shared_ptr<MCP::ProcessCallToolRequest>& spTask)
+	{
+		m_spFallbackCallToolTask = spTask;
+	}
+
+	void CMCPSession::SetServerCallToolsTasks(const std::unordered_map<std::string, std::shared_ptr<MCP::ProcessCallToolRequest>>& hashCallToolsTasks)
+	{
+		m_hashCallToolsTasks = hashCallToolsTasks;
+	}
+
+	MCP::Implementation CMCPSession::GetServerInfo() const
+	{
+		return m_serverInfo;
+	}
+
+	// Capabilities and the method a client lists them with, disabling the method withdraws the capability
+	static const std::pair<const char*, const char*> s_arrCapabilityMethods[] = {
+		{ MSG_KEY_TOOLS, METHOD_TOOLS_LIST },
+		{ MSG_KEY_RESOURCES, METHOD_RESOURCES_LIST },
+		{ MSG_KEY_PROMPTS, METHOD_PROMPTS_LIST },
+		{ MSG_KEY_LOGGING, METHOD_LOGGING_SET_LEVEL },
+	};
+
+	int CMCPSession::SetServerCapabilitiesOverride(const Json::Value& jCapabilities)
+	{
+		if (jCapabilities.isNull())
+		{
+			m_jCapabilitiesOverride = Json::Value();
+			return ERRNO_OK;
+		}
+		if (!jCapabilities.isObject())
+			return ERRNO_INVALID_PARAMS;
+		for (const auto& capabilityMethod : s_arrCapabilityMethods)
+		{
+			if (jCapabilities.isMember(capabilityMethod.first) && IsServerMethodDisabled(capabilityMethod.second))
+				return ERRNO_INVALID_PARAMS;
+		}
+
+		m_jCapabilitiesOverride = jCapabilities;
+
+		return ERRNO_OK;
+	}
+
+	Json::Value CMCPSession::GetServerCapabilitiesOverride() const
+	{
+		if (!m_jCapabilitiesOverride.isObject())
+			return m_jCapabilitiesOverride;
+
+		Json::Value jCapabilities = m_jCapabilitiesOverride;
+		for (const auto& capabilityMethod : s_arrCapabilityMethods)
+		{
+			if (IsServerMethodDisabled(capabilityMethod.second))
+				jCapabilities.removeMember(capabilityMethod.first);
+		}
+
+		return jCapabilities;
+	}
+
+	MCP::ServerCapabilities CMCPSession::GetServerCapabilities() const
+	{
+		MCP::ServerCapabilities capabilities = m_capabilities;
+		if (IsServerMethodDisabled(METHOD_TOOLS_LIST))
+			capabilities.tools.bExist = false;
+		if (IsServerMethodDisabled(METHOD_RESOURCES_LIST))
+			capabilities.resources.bExist = false;
+		if (IsServerMethodDisabled(METHOD_PROMPTS_LIST))
+			capabilities.prompts.bExist = false;
+		if (IsServerMethodDisabled(METHOD_LOGGING_SET_LEVEL))
+			capabilities.logging.bExist = false;
+
+		return capabilities;
+	}
+
+	std::string CMCPSession::GetServerInstructions(const std::string& strClientName) const
+	{
+		std::string strInstructions = m_strInstructions;
+		auto itrFound = m_hashClientInstructions.find(strClientName);
+		if (itrFound != m_hashClientInstructions.end())
+		{
+			strInstructions = itrFound->second;
+		}
+		else
+		{
+			std::string strPath = m_strInstructionsFile;
+			if (strPath.empty())
+			{
+				const char* lpcszPath = std::getenv("TINYMCP_INSTRUCTIONS_FILE");
+				if (lpcszPath)
+					strPath = lpcszPath;
+			}
+			if (!strPath.empty())
+			{
+				std::ifstream ifs(strPath, std::ios::binary);
+				if (ifs)
+				{
+					std::stringstream ss;
+					ss << ifs.rdbuf();
+					strInstructions = ss.str();
+					while (!strInstructions.empty() && (strInstructions.back() == '\n' || strInstructions.back() == '\r'))
+						strInstructions.pop_back();
+				}
+				else if (m_spTransport)
+				{
+					m_spTransport->Error(u8"cannot read instructions file " + strPath + u8", using the default instructions");
+				}
+			}
+		}
+
+		return StringHelper::expand_variables(strInstructions, [](const std::string& strName, std::string& strValue)
+			{
+				const char* lpcszEnv = std::getenv(strName.c_str());
+				if (lpcszEnv)
+				{
+					strValue = lpcszEnv;
+					return true;
+				}
+
+				auto nDot = strName.find('.');
+				if (std::string::npos == nDot)
+					return false;
+				auto strSection = strName.substr(0, nDot);
+				auto strKey = strName.substr(nDot + 1);
+				auto& config = Config::GetInstance();
+				if (!config.HasKey(strSection, strKey))
+					return false;
+				strValue = config.GetString(strSection, strKey);
+				return true;
+			});
+	}
+
+	bool CMCPSession::GetServerToolsPagination() const
+	{
+		return m_bToolsPagination;
+	}
+
+	void CMCPSession::ValidateServerTools(std::vector<std::string>& vecIssues) const
+	{
+		std::unordered_map<std::string, std::size_t> hashFirstIndex;
+		for (std::size_t i = 0; i < m_tools.size(); ++i)
+		{
+			const auto& tool = m_tools[i];
+			std::string strEntry = std::string(MSG_KEY_TOOLS) + u8"[" + std::to_string(i) + u8"]";
+			if (!tool.strName.empty())
+				strEntry += u8" " + tool.strName;
+
+			Json::Value jTool(Json::objectValue);
+			std::string strIssue;
+			if (ERRNO_OK != tool.DoSerialize(jTool) || ERRNO_OK != MCP::Tool::CheckDefinition(jTool, strIssue))
+			{
+				vecIssues.push_back(strEntry + u8": " + strIssue);
+				continue;
+			}
+
+			bool bWellFormed = tool.strName.size() <= 128 && std::all_of(tool.strName.begin(), tool.strName.end(), [](char ch)
+			{
+				return std::isalnum(static_cast<unsigned char>(ch)) || ch == '_' || ch == '-' || ch == '.';
+			});
+			if (!bWellFormed)
+				vecIssues.push_back(strEntry + u8": name must be at most 128 characters of [A-Za-z0-9_.-]");
+
+			auto itrFirst = hashFirstIndex.find(tool.strName);
+			if (itrFirst != hashFirstIndex.end())
+				vecIssues.push_back(strEntry + u8": duplicate name, first registered as " + std::string(MSG_KEY_TOOLS) + u8"[" + std::to_string(itrFirst->second) + u8"]");
+			else
+				hashFirstIndex[tool.strName] = i;
+		}
+	}
+
+	std::string CMCPSession::GetClientLocale() const
+	{
+		return m_strClientLocale;
+	}
+
+	std::vector<MCP::Tool> CMCPSession::GetServerTools() const
+	{
+		return m_tools;
+	}
+
+	void CMCPSession::SetServerArgumentCoercion(bool bCoercion)
+	{
+		m_bArgumentCoercion = bCoercion;
+	}
+
+	bool CMCPSession::GetServerArgumentCoercion() const
+	{
+		return m_bArgumentCoercion;
+	}
+
+	void CMCPSession::SetServerStrictParsing(bool bStrict)
+	{
+		m_bStrictParsing = bStrict;
+	}
+
+	void CMCPSession::SetServerArgumentLimits(unsigned int nMaxBytes, unsigned int nMaxDepth)
+	{
+		m_nMaxArgumentBytes = nMaxBytes;
+		m_nMaxArgumentDepth = nMaxDepth;
+	}
+
+	void CMCPSession::SetServerMaxResultBytes(unsigned int nMaxBytes, bool bTruncate)
+	{
+		m_nMaxResultBytes = nMaxBytes;
+		m_bTruncateResult = bTruncate;
+	}
+
+	int CMCPSession::LimitToolResult(MCP::CallToolResult& result) const
+	{
+		if (0 == m_nMaxResultBytes)
+			return ERRNO_OK;
+
+		std::size_t nTotal = 0;
+		for (const auto& text : result.vecTextContent)
+			nTotal += text.strText.size();
+		if (nTotal <= m_nMaxResultBytes)
+			return ERRNO_OK;
+
+		if (!m_bTruncateResult)
+		{
+			MCP::TextContent textContent;
+			textContent.strType = CONST_TEXT;
+			textContent.strText = u8"The tool result is " + std::to_string(nTotal) + u8" bytes, which exceeds the limit of " + std::to_string(m_nMaxResultBytes) + u8" bytes.";
+			result.bIsError = true;
+			result.vecTextContent.assign(1, textContent);
+			result.vecImageContent.clear();
+			result.vecEmbeddedResource.clear();
+			result.vecResourceLinks.clear();
+
+			return ERRNO_OK;
+		}
+
+		// Keep whole text items while they fit, cut the first one that does not and drop the rest
+		std::size_t nBudget = m_nMaxResultBytes;
+		for (std::size_t i = 0; i < result.vecTextContent.size(); ++i)
+		{
+			auto& strText = result.vecTextContent[i].strText;
+			if (strText.size() <= nBudget)
+			{
+				nBudget -= strText.size();
+				continue;
+			}
+			// Cut on a UTF-8 character boundary
+			std::size_t nCut = nBudget;
+			while (nCut > 0 && (static_cast<unsigned char>(strText[nCut]) & 0xC0) == 0x80)
+				--nCut;
+			strText = strText.substr(0, nCut) + u8"\n[truncated]";
+			result.vecTextContent.resize(i + 1);
+			break;
+		}
+		result.jMeta["truncatedBytes"] = static_cast<Json::UInt64>(nTotal);
+
+		return ERRNO_OK;
+	}
+
+	void CMCPSession::SetServerEmptyResultText(const std::string& strText)
+	{
+		m_strEmptyResultText = strText;
+	}
+
+	std::string CMCPSession::GetServerEmptyResultText() const
+	{
+		return m_strEmptyResultText;
+	}
+
+	void CMCPSession::SetServerSchemaVersionCheck(bool bCheck)
+	{
+		m_bSchemaVersionCheck = bCheck;
+	}
+
+	void CMCPSession::SetServerTimingMeta(bool bTimingMeta)
+	{
+		m_bTimingMeta = bTimingMeta;
+	}
+
+	bool CMCPSession::GetServerTimingMeta() const
+	{
+		return m_bTimingMeta;
+	}
+
+	void CMCPSession::SetServerDisabledMethods(const std::vector<std::string>& vecMethods)
+	{
+		m_vecDisabledMethods = vecMethods;
+	}
+
+	bool CMCPSession::IsServerMethodDisabled(const std::string& strMethod) const
+	{
+		if (strMethod.compare(METHOD_INITIALIZE) == 0)
+			return false;
+
+		return std::find(m_vecDisabledMethods.begin(), m_vecDisabledMethods.end(), strMethod) != m_vecDisabledMethods.end();
+	}
+
+	bool CMCPSession::GetServerStrictParsing() const
+	{
+		return m_bStrictParsing;
+	}
+
+	void CMCPSession::SetServerIdempotencyTTL(unsigned int nSeconds)
+	{
+		m_nIdempotencyTTL = nSeconds;
+	}
+
+	int CMCPSession::CacheIdempotentResult(const std::shared_ptr<MCP::Request>& spRequest, const MCP::CallToolResult& result)
+	{
+		auto spCallToolRequest = std::dynamic_pointer_cast<MCP::CallToolRequest>(spRequest);
+		if (!spCallToolRequest || spCallToolRequest->strIdempotencyKey.empty() || 0 == m_nIdempotencyTTL)
+			return ERRNO_OK;
+
+		const std::lock_guard<std::mutex> _lock(m_mtxIdempotency);
+		auto itrFound = m_hashIdempotentResults.find(spCallToolRequest->strIdempotencyKey);
+		if (itrFound == m_hashIdempotentResults.end() || !itrFound->second.requestId.IsEqual(spCallToolRequest->requestId))
+			return ERRNO_OK;
+		itrFound->second.bInFlight = false;
+		itrFound->second.tpExpiry = std::chrono::steady_clock::now() + std::chrono::seconds(m_nIdempotencyTTL);
+		itrFound->second.result = result;
+
+		return ERRNO_OK;
+	}
+
+	void CMCPSession::ReleaseIdempotencyKey(const std::shared_ptr<MCP::Request>& spRequest)
+	{
+		auto spCallToolRequest = std::dynamic_pointer_cast<MCP::CallToolRequest>(spRequest);
+		if (!spCallToolRequest || spCallToolRequest->strIdempotencyKey.empty())
+			return;
+
+		const std::lock_guard<std::mutex> _lock(m_mtxIdempotency);
+		auto itrFound = m_hashIdempotentResults.find(spCallToolRequest->strIdempotencyKey);
+		if (itrFound != m_hashIdempotentResults.end() && itrFound->second.bInFlight && itrFound->second.requestId.IsEqual(spCallToolRequest->requestId))
+			m_hashIdempotentResults.erase(itrFound);
+	}
+
+	int CMCPSession::BeginIdempotentCall(const std::shared_ptr<MCP::CallToolRequest>& spRequest, bool& bReplayed, bool& bReserved, std::string& strMessage)
+	{
+		bReplayed = false;
+		bReserved = false;
+		if (!spRequest || spRequest->strIdempotencyKey.empty() || 0 == m_nIdempotencyTTL)
+			return ERRNO_OK;
+
+		Json::FastWriter writer;
+		writer.omitEndingLineFeed();
+		auto strFingerprint = spRequest->strName + u8"\n" + writer.write(spRequest->jArguments);
+
+		std::unique_lock<std::mutex> _lock(m_mtxIdempotency);
+		auto tpNow = std::chrono::steady_clock::now();
+		// Calls in flight expire as well, so a task that never reports back does not hold its key forever
+		for (auto itr = m_hashIdempotentResults.begin(); itr != m_hashIdempotentResults.end();)
+		{
+			if (itr->second.tpExpiry <= tpNow)
+				itr = m_hashIdempotentResults.erase(itr);
+			else
+				++itr;
+		}
+		auto itrFound = m_hashIdempotentResults.find(spRequest->strIdempotencyKey);
+		if (itrFound == m_hashIdempotentResults.end())
+		{
+			IdempotentCall call;
+			call.strFingerprint = strFingerprint;
+			call.requestId = spRequest->requestId;
+			call.tpExpiry = tpNow + std::chrono::seconds(m_nIdempotencyTTL);
+			m_hashIdempotentResults[spRequest->strIdempotencyKey] = call;
+			bReserved = true;
+			return ERRNO_OK;
+		}
+		if (itrFound->second.strFingerprint != strFingerprint)
+		{
+			strMessage = std::string(ERROR_MESSAGE_INVALID_PARAMS) + u8": " + ERROR_MESSAGE_IDEMPOTENCY_KEY_REUSED;
+			return ERRNO_INVALID_PARAMS;
+		}
+		if (itrFound->second.bInFlight)
+		{
+			strMessage = std::string(ERROR_MESSAGE_SERVER_BUSY) + u8": " + ERROR_MESSAGE_IDEMPOTENCY_KEY_IN_FLIGHT;
+			return ERRNO_SERVER_BUSY;
+		}
+		auto result = itrFound->second.result;
+		_lock.unlock();
+
+		result.requestId = spRequest->requestId;
+		auto jEchoedMeta = spRequest->GetEchoedMeta();
+		for (const auto& strKey : jEchoedMeta.getMemberNames())
+			result.jMeta[strKey] = jEchoedMeta[strKey];
+		std::string strResponse;
+		if (ERRNO_OK != result.Serialize(strResponse))
+			return ERRNO_INTERNAL_ERROR;
+		if (!m_spTransport || ERRNO_OK != m_spTransport->Write(strResponse))
+			return ERRNO_INTERNAL_ERROR;
+		bReplayed = true;
+
+		return ERRNO_OK;
+	}
+
+	void CMCPSession::SetServerAuditLogger(const std::shared_ptr<CMCPAuditLogger>& spAuditLogger)
+	{
+		m_spAuditLogger = spAuditLogger;
+	}
+
+	void CMCPSession::SetServerAuditRedactedArguments(const std::vector<std::string>& vecArgumentNames)
+	{
+		m_vecAuditRedactedArguments = vecArgumentNames;
+	}
+
+	void CMCPSession::SetServerRedactedFields(const std::vector<std::string>& vecFields)
+	{
+		m_vecRedactedFields = vecFields;
+	}
+
+	void CMCPSession::SetServerDeadLetterLogger(const std::shared_ptr<CMCPAuditLogger>& spDeadLetterLogger)
+	{
+		m_spDeadLetterLogger = spDeadLetterLogger;
+	}
+
+	std::vector<std::string> CMCPSession::GetServerRedactedFields() const
+	{
+		return m_vecRedactedFields;
+	}
+
+	void CMCPSession::AuditToolCall(const std::shared_ptr<MCP::Request>& spRequest, const std::string& strOutcome, int iCode, const std::string& strMessage)
+	{
+		if (!m_spAuditLogger || !spRequest)
+			return;
+
+		Json::Value jEntry(Json::objectValue);
+		jEntry["time"] = GetUtcTimestamp();
+		spRequest->requestId.DoSerialize(jEntry);
+		if (jEntry.isMember(MSG_KEY_ID))
+		{
+			jEntry[MSG_KEY_REQUEST_ID] = jEntry[MSG_KEY_ID];
+			jEntry.removeMember(MSG_KEY_ID);
+		}
+		jEntry["client"] = m_clientInfo.strName;
+		auto spCallToolRequest = std::dynamic_pointer_cast<MCP::CallToolRequest>(spRequest);
+		if (spCallToolRequest)
+		{
+			jEntry["tool"] = spCallToolRequest->strName;
+			Json::Value jArguments = spCallToolRequest->jArguments.isNull() ? Json::Value(Json::objectValue) : spCallToolRequest->jArguments;
+			RedactHelper::redact_fields(jArguments, m_vecAuditRedactedArguments);
+			RedactHelper::redact_fields(jArguments, m_vecRedactedFields);
+			jEntry[MSG_KEY_ARGUMENTS] = jArguments;
+		}
+		jEntry["outcome"] = strOutcome;
+		if (ERRNO_OK != iCode)
+		{
+			int iResponseCode = ErrorHelper::to_response_code(iCode);
+			jEntry[MSG_KEY_CODE] = iResponseCode;
+			jEntry[MSG_KEY_MESSAGE] = strMessage.empty() ? std::string(ErrorHelper::get_message(iResponseCode)) : strMessage;
+		}
+
+		m_spAuditLogger->Record(jEntry);
+	}
+
+	void CMCPSession::SetServerMaxConcurrentCalls(unsigned int nMaxCalls, unsigned int nMaxWaitMs)
+	{
+		m_nMaxConcurrentCalls = nMaxCalls;
+		m_nMaxCallWaitMs = nMaxWaitMs;
+	}
+
+	unsigned int CMCPSession::GetServerMaxConcurrentCalls() const
+	{
+		return m_nMaxConcurrentCalls;
+	}
+
+	void CMCPSession::SetServerInitializeTimeout(unsigned int nMilliseconds)
+	{
+		m_nInitializeTimeoutMs = nMilliseconds;
+	}
+
+	void CMCPSession::SetServerErrorVerbosity(ErrorVerbosity eVerbosity)
+	{
+		m_eErrorVerbosity = eVerbosity;
+	}
+
+	CMCPSession::ErrorVerbosity CMCPSession::GetServerErrorVerbosity() const
+	{
+		return m_eErrorVerbosity;
+	}
+
+	std::shared_ptr<CMCPTransport> CMCPSession::GetTransport() const
+	{
+		return m_spTransport;
+	}
+
+	int CMCPSession::SwitchState(SessionState eState)
+	{
+		if (SessionState_Initializing == eState)
+		{
+			if (SessionState_Original != m_eSessionState)
+				return ERRNO_INTERNAL_ERROR;
+		}
+
+		if (SessionState_Initialized == eState)
+		{
+			if (SessionState_Initializing != m_eSessionState)
+				return ERRNO_INTERNAL_ERROR;
+		}
+
+		m_eSessionState = eState;
+
+		return ERRNO_OK;
+	}
+
+	CMCPSession::SessionState CMCPSession::GetSessionState() const
+	{
+		return m_eSessionState;
+	}
+
+	std::shared_ptr<MCP::ProcessRequest> CMCPSession::GetServerCallToolsTask(const std::string& strToolName)
+	{
+		if (m_hashCallToolsTasks.count(strToolName) > 0)
+			return m_hashCallToolsTasks[strToolName];
+
+		return nullptr;
+	}
+
+	const MCP::Tool* CMCPSession::FindServerTool(const std::string& strToolName) const
+	{
+		auto itrTool = std::find_if(m_tools.begin(), m_tools.end(), [&strToolName](const MCP::Tool& tool)
+			{
+				return tool.strName == strToolName;
+			});
+		if (itrTool == m_tools.end())
+			return nullptr;
+
+		return &(*itrTool);
+	}
+
+	int CMCPSession::CheckToolArgumentLimits(const Json::Value& jArguments, std::string& strMessage) const
+	{
+		if (m_nMaxArgumentDepth > 0)
+		{
+			std::function<unsigned int(const Json::Value&)> fnDepth = [&fnDepth](const Json::Value& jValue) -> unsigned int
+			{
+				if (!jValue.isObject() && !jValue.isArray())
+					return 0;
+				unsigned int nMaxChild = 0;
+				for (const auto& jChild : jValue)
+					nMaxChild = (std::max)(nMaxChild, fnDepth(jChild));
+				return nMaxChild + 1;
+			};
+			if (fnDepth(jArguments) > m_nMaxArgumentDepth)
+			{
+				strMessage = std::string(ERROR_MESSAGE_INVALID_PARAMS) + u8": arguments nested deeper than " + std::to_string(m_nMaxArgumentDepth) + u8" levels";
+				return ERRNO_INVALID_PARAMS;
+			}
+		}
+
+		if (m_nMaxArgumentBytes > 0)
+		{
+			Json::FastWriter writer;
+			auto strArguments = writer.write(jArguments);
+			if (!strArguments.empty() && '\n' == strArguments.back())
+				strArguments.pop_back();
+			if (strArguments.size() > m_nMaxArgumentBytes)
+			{
+				strMessage = std::string(ERROR_MESSAGE_INVALID_PARAMS) + u8": arguments are " + std::to_string(strArguments.size()) + u8" bytes, the limit is " + std::to_string(m_nMaxArgumentBytes);
+				return ERRNO_INVALID_PARAMS;
+			}
+		}
+
+		return ERRNO_OK;
+	}
+
+	int CMCPSession::CoerceToolArguments(const std::string& strToolName, Json::Value& jArguments, std::string& strMessage) const
+	{
+		if (!jArguments.isObject())
+			return ERRNO_OK;
+
+		auto pTool = FindServerTool(strToolName);
+		if (!pTool)
+			return ERRNO_OK;
+		auto& jSchema = pTool->jInputSchema;
+		if (!jSchema.isObject() || !jSchema.isMember("properties") || !jSchema["properties"].isObject())
+			return ERRNO_OK;
+		auto& jProperties = jSchema["properties"];
+
+		// Only plain decimal notation is converted, the standard conversions would also accept
+		// surrounding whitespace, "nan", "inf" and hexadecimal
+		auto fnIsDecimal = [](const std::string& strValue, bool bInteger)
+		{
+			std::size_t nPos = (!strValue.empty() && strValue[0] == '-') ? 1 : 0;
+			auto fnDigits = [&strValue, &nPos]()
+			{
+				auto nStart = nPos;
+				while (nPos < strValue.size() && std::isdigit(static_cast<unsigned char>(strValue[nPos])))
+					++nPos;
+				return nPos > nStart;
+			};
+			if (!fnDigits())
+				return false;
+			if (!bInteger && nPos < strValue.size() && strValue[nPos] == '.')
+			{
+				++nPos;
+				if (!fnDigits())
+					return false;
+			}
+			if (!bInteger && nPos < strValue.size() && (strValue[nPos] == 'e' || strValue[nPos] == 'E'))
+			{
+				++nPos;
+				if (nPos < strValue.size() && (strValue[nPos] == '+' || strValue[nPos] == '-'))
+					++nPos;
+				if (!fnDigits())
+					return false;
+			}
+			return nPos == strValue.size();
+		};
+
+		for (const auto& strName : jArguments.getMemberNames())
+		{
+			auto& jValue = jArguments[strName];
+			if (!jValue.isString() || !jProperties.isMember(strName) || !jProperties[strName].isObject())
+				continue;
+			auto& jType = jProperties[strName]["type"];
+			if (!jType.isString())
+				continue;
+
+			auto strType = jType.asString();
+			auto strValue = jValue.asString();
+			bool bConverted = true;
+			if (strType == "integer")
+			{
+				try
+				{
+					std::size_t nUsed = 0;
+					bConverted = fnIsDecimal(strValue, true);
+					auto llValue = bConverted ? std::stoll(strValue, &nUsed) : 0;
+					bConverted = bConverted && (nUsed == strValue.size());
+					if (bConverted)
+						jValue = Json::Value(static_cast<Json::Int64>(llValue));
+				}
+				catch (const std::exception&)
+				{
+					bConverted = false;
+				}
+			}
+			else if (strType == "number")
+			{
+				try
+				{
+					std::size_t nUsed = 0;
+					bConverted = fnIsDecimal(strValue, false);
+					auto dValue = bConverted ? std::stod(strValue, &nUsed) : 0.0;
+					bConverted = bConverted && (nUsed == strValue.size()) && std::isfinite(dValue);
+					if (bConverted)
+						jValue = Json::Value(dValue);
+				}
+				catch (const std::exception&)
+				{
+					bConverted = false;
+				}
+			}
+			else if (strType == "boolean")
+			{
+				bConverted = (strValue == "true" || strValue == "false");
+				if (bConverted)
+					jValue = Json::Value(strValue == "true");
+			}
+
+			if (!bConverted)
+			{
+				strMessage = std::string(ERROR_MESSAGE_INVALID_PARAMS) + u8": argument '" + strName + u8"' is not a valid " + strType;
+				return ERRNO_INVALID_PARAMS;
+			}
+		}
+
+		return ERRNO_OK;
+	}
+
+	int CMCPSession::CheckToolSchemaVersion(const std::shared_ptr<MCP::CallToolRequest>& spRequest, std::string& strMessage) const
+	{
+		if (!m_bSchemaVersionCheck || !spRequest)
+			return ERRNO_OK;
+		auto pTool = FindServerTool(spRequest->strName);
+		if (!pTool || pTool->strSchemaVersion.empty())
+			return ERRNO_OK;
+		if (!spRequest->jMeta.isObject() || !spRequest->jMeta.isMember(MSG_KEY_SCHEMA_VERSION))
+			return ERRNO_OK;
+
+		auto& jVersion = spRequest->jMeta[MSG_KEY_SCHEMA_VERSION];
+		if (jVersion.isString() && jVersion.asString() == pTool->strSchemaVersion)
+			return ERRNO_OK;
+
+		strMessage = std::string(ERROR_MESSAGE_SCHEMA_CHANGED) + u8": " + spRequest->strName + u8" is at schema version " + pTool->strSchemaVersion;
+		return ERRNO_INVALID_PARAMS;
+	}
+
+	int CMCPSession::CheckToolArgumentTypes(const std::string& strToolName, const Json::Value& jArguments, std::string& strMessage) const
+	{
+		if (!jArguments.isObject())
+			return ERRNO_OK;
+
+		auto pTool = FindServerTool(strToolName);
+		if (!pTool)
+			return ERRNO_OK;
+		auto& jSchema = pTool->jInputSchema;
+		if (!jSchema.isObject() || !jSchema.isMember("properties") || !jSchema["properties"].isObject())
+			return ERRNO_OK;
+		auto& jProperties = jSchema["properties"];
+
+		// A value of the wrong type would otherwise only fail inside the tool task, e.g. when jsoncpp's asInt throws
+		static const std::unordered_map<std::string, std::function<bool(const Json::Value&)>> s_hashTypeChecks = {
+			{ "string", [](const Json::Value& jValue) { return jValue.isString(); } },
+			{ "integer", [](const Json::Value& jValue) { return jValue.isIntegral() && !jValue.isBool(); } },
+			{ "number", [](const Json::Value& jValue) { return jValue.isNumeric() && !jValue.isBool(); } },
+			{ "boolean", [](const Json::Value& jValue) { return jValue.isBool(); } },
+			{ "object", [](const Json::Value& jValue) { return jValue.isObject(); } },
+			{ "array", [](const Json::Value& jValue) { return jValue.isArray(); } },
+			{ "null", [](const Json::Value& jValue) { return jValue.isNull(); } },
+		};
+
+		for (const auto& strName : jArguments.getMemberNames())
+		{
+			if (!jProperties.isMember(strName) || !jProperties[strName].isObject())
+				continue;
+			auto& jType = jProperties[strName]["type"];
+			std::vector<std::string> vecTypes;
+			if (jType.isString())
+				vecTypes.push_back(jType.asString());
+			else if (jType.isArray())
+			{
+				for (const auto& jItem : jType)
+				{
+					if (jItem.isString())
+						vecTypes.push_back(jItem.asString());
+				}
+			}
+
+			bool bMatched = vecTypes.empty();
+			for (const auto& strType : vecTypes)
+			{
+				auto itrCheck = s_hashTypeChecks.find(strType);
+				// Types the SDK does not know are left to the tool
+				if (itrCheck == s_hashTypeChecks.end() || itrCheck->second(jArguments[strName]))
+				{
+					bMatched = true;
+					break;
+				}
+			}
+			if (!bMatched)
+			{
+				std::string strTypes;
+				for (const auto& strType : vecTypes)
+					strTypes += (strTypes.empty() ? u8"" : u8" or ") + strType;
+				strMessage = std::string(ERROR_MESSAGE_INVALID_PARAMS) + u8": argument '" + strName + u8"' must be " + strTypes;
+				return ERRNO_INVALID_PARAMS;
+			}
+		}
+
+		return ERRNO_OK;
+	}
+
+	int CMCPSession::ApplyToolArgumentDefaults(const std::string& strToolName, Json::Value& jArguments, std::string& strMessage) const
+	{
+		auto pTool = FindServerTool(strToolName);
+		if (!pTool || !pTool->jInputSchema.isObject())
+		{
+			// Without a schema, non-object arguments are dropped as before.
+			if (!jArguments.isObject())
+				jArguments = Json::Value();
+			return ERRNO_OK;
+		}
+		auto& jSchema = pTool->jInputSchema;
+		if (!jArguments.isNull() && !jArguments.isObject())
+		{
+			strMessage = std::string(ERROR_MESSAGE_INVALID_PARAMS) + u8": arguments must be an object";
+			return ERRNO_INVALID_PARAMS;
+		}
+
+		if (jSchema.isMember("properties") && jSchema["properties"].isObject())
+		{
+			auto& jProperties = jSchema["properties"];
+			for (const auto& strName : jProperties.getMemberNames())
+			{
+				auto& jProperty = jProperties[strName];
+				if (!jProperty.isObject() || !jProperty.isMember("default"))
+					continue;
+				if (jArguments.isNull())
+					jArguments = Json::Value(Json::objectValue);
+				if (!jArguments.isMember(strName))
+					jArguments[strName] = jProperty["default"];
+			}
+		}
+
+		if (jSchema.isMember("required") && jSchema["required"].isArray())
+		{
+			for (const auto& jRequired : jSchema["required"])
+			{
+				if (!jRequired.isString())
+					continue;
+				if (!jArguments.isObject() || !jArguments.isMember(jRequired.asString()))
+				{
+					strMessage = std::string(ERROR_MESSAGE_INVALID_PARAMS) + u8": missing required argument '" + jRequired.asString() + u8"'";
+					return ERRNO_INVALID_PARAMS;
+				}
+			}
+		}
+
+		return ERRNO_OK;
+	}
+
+	int CMCPSession::CommitAsyncTask(const std::shared_ptr<MCP::CMCPTask>& spTask)
+	{
+		if (!spTask)
+			return ERRNO_INTERNAL_ERROR;
+
+		if (m_bRunAsyncTask) 
+		{
+			std::unique_lock<std::mutex> _lock(m_mtxAsyncThread);
+
+			if (!m_bRunAsyncTask)
+			{
+				_lock.unlock();
+				return ERRNO_OK;
+			}
+
+			m_deqAsyncTasks.push_back(spTask);
 
 			_lock.unlock();
 			m_cvAsyncThread.notify_one();
@@ -698,8 +1958,25 @@ namespace MCP
 		return ERRNO_OK;
 	}
 
+	int CMCPSession::RejectAsyncTask(const std::shared_ptr<MCP::CMCPTask>& spTask, int iErrCode)
+	{
+		auto spProcessRequestTask = std::dynamic_pointer_cast<MCP::ProcessRequest>(spTask);
+		if (!spProcessRequestTask)
+			return ERRNO_INTERNAL_ERROR;
+
+		auto spErrorTask = std::make_shared<ProcessErrorRequest>(spProcessRequestTask->GetRequest());
+		if (!spErrorTask)
+			return ERRNO_INTERNAL_ERROR;
+		spErrorTask->SetErrorCode(iErrCode);
+		AuditToolCall(spProcessRequestTask->GetRequest(), u8"rejected", iErrCode);
+		ReleaseIdempotencyKey(spProcessRequestTask->GetRequest());
+
+		return spErrorTask->Execute();
+	}
+
 	int CMCPSession::StartAsyncTaskThread()
 	{
+		m_bRunAsyncTask = true;
 		m_upTaskThread = std::make_unique<std::thread>(&CMCPSession::AsyncThreadProc, this);
 		if (!m_upTaskThread)
 			return ERRNO_INTERNAL_ERROR;
@@ -707,6 +1984,15 @@ namespace MCP
 		return ERRNO_OK;
 	}
 
+	void CMCPSession::NotifyAsyncTaskFinished()
+	{
+		{
+			const std::lock_guard<std::mutex> _lock(m_mtxAsyncThread);
+			m_bAsyncTaskFinished = true;
+		}
+		m_cvAsyncThread.notify_one();
+	}
+
 	int CMCPSession::StopAsyncTaskThread()
 	{
 		std::unique_lock<std::mutex> _lock(m_mtxAsyncThread);
@@ -718,18 +2004,90 @@ namespace MCP
 		return ERRNO_OK;
 	}
 
+	int CMCPSession::StartInitializeWatchdog()
+	{
+		StopInitializeWatchdog();
+		{
+			const std::lock_guard<std::mutex> _lock(m_mtxInitializeWatchdog);
+			m_bInitializeReceived = false;
+			m_bStopInitializeWatchdog = false;
+		}
+		m_upInitializeWatchdog = std::make_unique<std::thread>(&CMCPSession::InitializeWatchdogProc, this);
+		if (!m_upInitializeWatchdog)
+			return ERRNO_INTERNAL_ERROR;
+
+		return ERRNO_OK;
+	}
+
+	bool CMCPSession::IsAsyncTaskThreadRunning() const
+	{
+		return nullptr != m_upTaskThread;
+	}
+
+	bool CMCPSession::IsInitializeWatchdogRunning() const
+	{
+		return nullptr != m_upInitializeWatchdog;
+	}
+
+	int CMCPSession::StopInitializeWatchdog()
+	{
+		{
+			const std::lock_guard<std::mutex> _lock(m_mtxInitializeWatchdog);
+			m_bStopInitializeWatchdog = true;
+		}
+		m_cvInitializeWatchdog.notify_all();
+
+		if (m_upInitializeWatchdog && m_upInitializeWatchdog->joinable())
+			m_upInitializeWatchdog->join();
+		m_upInitializeWatchdog.reset();
+
+		return ERRNO_OK;
+	}
+
+	int CMCPSession::InitializeWatchdogProc()
+	{
+		const unsigned int nTimeoutMs = m_nInitializeTimeoutMs;
+		std::unique_lock<std::mutex> _lock(m_mtxInitializeWatchdog);
+		if (m_cvInitializeWatchdog.wait_for(_lock, std::chrono::milliseconds(nTimeoutMs),
+			[this]() { return m_bInitializeReceived || m_bStopInitializeWatchdog; }))
+			return ERRNO_OK;
+		m_bInitializeTimedOut = true;
+		_lock.unlock();
+
+		if (m_spTransport)
+		{
+			m_spTransport->Error(u8"initialize not received within " + std::to_string(nTimeoutMs) + u8" ms, closing the connection");
+			m_spTransport->Disconnect();
+		}
+
+		return ERRNO_OK;
+	}
+
 	int CMCPSession::AsyncThreadProc()
 	{
 		while (m_bRunAsyncTask) 
 		{
 			std::unique_lock<std::mutex> _lock(m_mtxAsyncThread);
 
-			// Wait for tasks
-			m_cvAsyncThread.wait(
-				_lock, 
-				[this]() {
-				return !m_deqAsyncTasks.empty() || !m_vecCancelledTaskIds.empty() || !m_bRunAsyncTask;
-				});
+			// Wait for tasks. While calls wait for a free slot, a finished task or the end of
+			// the earliest wait also wakes the thread.
+			auto fnWakeUp = [this]() {
+				return !m_deqAsyncTasks.empty() || !m_vecCancelledTaskIds.empty() || !m_bRunAsyncTask
+					|| (m_bAsyncTaskFinished && !m_deqWaitingTasks.empty());
+				};
+			if (m_deqWaitingTasks.empty())
+			{
+				m_cvAsyncThread.wait(_lock, fnWakeUp);
+			}
+			else
+			{
+				auto itrEarliest = std::min_element(m_deqWaitingTasks.begin(), m_deqWaitingTasks.end(), [](auto& left, auto& right)
+					{
+						return left.first < right.first;
+					});
+				m_cvAsyncThread.wait_until(_lock, itrEarliest->first, fnWakeUp);
+			}
+			m_bAsyncTaskFinished = false;
 
 			// Break the loop and clean up tasks
 			if (!m_bRunAsyncTask)
@@ -747,17 +2105,19 @@ namespace MCP
 				break;
 			}
 
-			// Process new pending tasks
-			std::vector<std::shared_ptr<MCP::CMCPTask>> vecTasks;
+			// Process new pending tasks, behind those already waiting for a free slot
+			const auto tpNow = std::chrono::steady_clock::now();
+			std::vector<std::pair<std::chrono::steady_clock::time_point, std::shared_ptr<MCP::CMCPTask>>> vecTasks(m_deqWaitingTasks.begin(), m_deqWaitingTasks.end());
+			m_deqWaitingTasks.clear();
 			while (!m_deqAsyncTasks.empty())
 			{
 				auto spTask = m_deqAsyncTasks.front();
-				vecTasks.push_back(spTask);
+				vecTasks.push_back(std::make_pair(tpNow + std::chrono::milliseconds(m_nMaxCallWaitMs), spTask));
 				m_deqAsyncTasks.pop_front();
 			}
 
-			//  Process task cancellation requests
-			std::for_each(m_vecAsyncTasksCache.begin(), m_vecAsyncTasksCache.end(), [this](auto& spTask) 
+			//  Process task cancellation requests, including tasks that have not started yet
+			auto fnCancelTask = [this](auto& spTask)
 				{
 					if (spTask)
 					{
@@ -779,30 +2139,64 @@ namespace MCP
 							}
 						}
 					}
-				});
+				};
+			std::for_each(m_vecAsyncTasksCache.begin(), m_vecAsyncTasksCache.end(), fnCancelTask);
+			std::for_each(vecTasks.begin(), vecTasks.end(), [&fnCancelTask](auto& task) { fnCancelTask(task.second); });
 			m_vecCancelledTaskIds.clear();
 			_lock.unlock();
 
-			// Clean up completed tasks
+			// Clean up completed tasks. Cancelled ones stay until they finish, their Execute may still
+			// be running and counts toward the concurrent call limit.
 			m_vecAsyncTasksCache.erase(
 				std::remove_if(m_vecAsyncTasksCache.begin(), m_vecAsyncTasksCache.end(), [](auto spTask) 
 					{
 						if (!spTask)
 							return true;
-						if (spTask->IsFinished() || spTask->IsCancelled())
+						if (spTask->IsFinished())
 							return true;
 						return false;
 					}),
 				m_vecAsyncTasksCache.end());
 
-			// Cache new tasks
-			for (auto& spTask : vecTasks)
+			// Cache new tasks, rejecting those beyond the concurrent call limit
+			auto nInFlight = std::count_if(m_vecAsyncTasksCache.begin(), m_vecAsyncTasksCache.end(), [](auto& spTask)
+				{
+					return spTask && !spTask->IsFinished();
+				});
+			for (auto& task : vecTasks)
 			{
-				if (spTask)
+				auto& spTask = task.second;
+				auto spProcessRequestTask = std::dynamic_pointer_cast<MCP::ProcessRequest>(spTask);
+				if (spTask && spTask->IsCancelled() && spProcessRequestTask)
 				{
-					if (ERRNO_OK == spTask->Execute())
+					AuditToolCall(spProcessRequestTask->GetRequest(), u8"cancelled");
+					ReleaseIdempotencyKey(spProcessRequestTask->GetRequest());
+				}
+				if (spTask && !spTask->IsCancelled())
+				{
+					if (m_nMaxConcurrentCalls > 0 && static_cast<unsigned int>(nInFlight) >= m_nMaxConcurrentCalls)
+					{
+						if (tpNow < task.first)
+							m_deqWaitingTasks.push_back(task);
+						else
+							RejectAsyncTask(spTask, ERRNO_SERVER_BUSY);
+						continue;
+					}
+
+					auto spCallToolTask = std::dynamic_pointer_cast<MCP::ProcessCallToolRequest>(spTask);
+					if (spCallToolTask)
+						spCallToolTask->MarkStarted();
+					int iErrCode = spTask->Execute();
+					if (ERRNO_OK == iErrCode)
 					{
 						m_vecAsyncTasksCache.push_back(spTask);
+						if (!spTask->IsFinished())
+							++nInFlight;
+					}
+					else if (spProcessRequestTask)
+					{
+						AuditToolCall(spProcessRequestTask->GetRequest(), u8"failed", iErrCode);
+						ReleaseIdempotencyKey(spProcessRequestTask->GetRequest());
 					}
 				}
 			}
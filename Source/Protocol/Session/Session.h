@@ -5,7 +5,6 @@
 #include <memory>
 #include <vector>
 #include <deque>
-#include <set>
 #include <unordered_map>
 #include <thread>
 #include <atomic>
@@ -13,9 +12,12 @@
 #include <mutex>
 #include "../Public/PublicDef.h"
 #include "../Message/Request.h"
+#include "../Message/Response.h"
+#include "../Message/Notification.h"
 #include "../Message/BasicMessage.h"
 #include "../Transport/Transport.h"
 #include "../Task/BasicTask.h"
+#include "../Audit/AuditLogger.h"
 
 namespace MCP
 {
@@ -31,6 +33,30 @@ namespace MCP
 			SessionState_Shut,
 		};
 
+		enum ErrorVerbosity
+		{
+			// Error responses carry no data, and failures on the server side only the default message of their code
+			ErrorVerbosity_Minimal,
+			// Error responses carry the specific message and data of the failure
+			ErrorVerbosity_Detailed,
+		};
+
+		// What SetServerTools does with a tool whose name is already taken, a warning is logged in every case
+		enum DuplicateToolPolicy
+		{
+			// Both definitions are registered, calls go to the first one
+			DuplicateToolPolicy_Keep,
+			// No tool is registered and SetServerTools fails
+			DuplicateToolPolicy_Error,
+			// The later definition replaces the earlier one in its place
+			DuplicateToolPolicy_LastWins,
+			// The later definition is dropped
+			DuplicateToolPolicy_FirstWins,
+			// The later definition is registered as name_2, name_3, ... and needs a task under that name,
+			// SetServerTools reports the new names
+			DuplicateToolPolicy_Suffix,
+		};
+
 		~CMCPSession() = default;
 		CMCPSession(const CMCPSession&) = delete;
 		CMCPSession& operator=(const CMCPSession&) = delete;
@@ -39,27 +65,138 @@ namespace MCP
 		int Ready();
 		int Run();
 		int Terminate();
+		// Parses and handles a single incoming message, replies go through the transport.
+		// Run calls this for every message read, transports that are not read-loop based can call it directly.
+		int Dispatch(const std::string& strIncomingMsg);
+
+		// Sends a notifications/message to the client when the logging capability is registered
+		// and strLevel is at or above the level requested through logging/setLevel (default "info").
+		int Log(const std::string& strLevel, const std::string& strText, const std::string& strLogger = "");
+		// Writes a notification to the client. Notifications that cannot be delivered, because the client has
+		// not initialized yet or the write fails, are handed to the dead-letter logger instead of being dropped.
+		int WriteNotification(const MCP::Notification& notification);
+		// Sends sampling/createMessage to the client and waits for its result. Fails with ERRNO_METHOD_NOT_FOUND
+		// when the client did not declare the sampling capability. Must not be called from the thread running Run,
+		// which is the one reading the client's reply; tool tasks run on the async task thread and may call it.
+		int RequestSampling(const Json::Value& jParams, Json::Value& jResult, unsigned int nTimeoutMs = 60000);
 
 		void SetTransport(const std::shared_ptr<CMCPTransport>& spTransport);
 		void SetServerInfo(const MCP::Implementation& impl);
 		void SetServerCapabilities(const MCP::ServerCapabilities& capabilities);
+		// Sent as the initialize capabilities instead of the registered ones, e.g. to declare experimental
+		// capabilities. It only changes what is advertised. Fails with ERRNO_INVALID_PARAMS when it is not an
+		// object or advertises a capability whose listing method is disabled; a null value removes the override.
+		int SetServerCapabilitiesOverride(const Json::Value& jCapabilities);
+		// Capabilities whose listing method was disabled after the override was set are left out
+		Json::Value GetServerCapabilitiesOverride() const;
+		// Returned in the initialize result. ${NAME} references are expanded from the
+		// environment, or from config values written as ${section.key}.
+		void SetServerInstructions(const std::string& strInstructions);
+		// Instructions used instead of the default ones when clientInfo.name matches strClientName.
+		void SetServerClientInstructions(const std::string& strClientName, const std::string& strInstructions);
+		// Reads the default instructions from strPath (or TINYMCP_INSTRUCTIONS_FILE when no path is set)
+		// on every initialize, so edits apply to the next session. Falls back to SetServerInstructions when unreadable.
+		void SetServerInstructionsFile(const std::string& strPath);
 		void SetServerToolsPagination(bool bPagination);
-		void SetServerTools(const std::vector<MCP::Tool>& tools);
+		// Must be set before the tools are registered
+		void SetServerDuplicateToolPolicy(DuplicateToolPolicy ePolicy);
+		// pvecRenamed, when given, receives the index in tools and the registered name of every renamed tool
+		int SetServerTools(const std::vector<MCP::Tool>& tools, std::vector<std::pair<std::size_t, std::string>>* pvecRenamed = nullptr);
 		void SetServerCallToolsTasks(const std::unordered_map<std::string, std::shared_ptr<MCP::ProcessCallToolRequest>>& hashCallToolsTasks);
+		// Runs tools/call requests for tools that are not registered, with the requested name and arguments as sent.
+		// Without one, which is the default, such calls fail with ERRNO_INVALID_PARAMS.
+		void SetServerFallbackCallToolTask(const std::shared_ptr<MCP::ProcessCallToolRequest>& spTask);
+		// When enabled, string arguments are converted to the integer, number or boolean type
+		// declared for them in the tool's input schema before the tool task runs.
+		void SetServerArgumentCoercion(bool bCoercion);
+		// When enabled, requests carrying members the SDK does not know, in the envelope or in the
+		// params of a known method, are rejected with ERRNO_INVALID_PARAMS. Tool arguments are not checked.
+		void SetServerStrictParsing(bool bStrict);
+		// When enabled, a tools/call whose _meta.schemaVersion differs from the tool's current strSchemaVersion
+		// fails with ERRNO_INVALID_PARAMS so the client re-lists tools. Calls without a version are not checked.
+		void SetServerSchemaVersionCheck(bool bCheck);
+		// Requests for these methods fail with ERRNO_METHOD_NOT_FOUND, and the capability whose listing method
+		// (tools/list, resources/list, prompts/list, logging/setLevel) is disabled is left out of initialize.
+		// initialize itself cannot be disabled.
+		void SetServerDisabledMethods(const std::vector<std::string>& vecMethods);
+		// When enabled, tools/call results carry _meta.timing with validationMs, queueMs, executeMs and totalMs.
+		// Off by default, it tells clients how the server spends its time.
+		void SetServerTimingMeta(bool bTimingMeta);
+		bool GetServerTimingMeta() const;
+		bool IsServerMethodDisabled(const std::string& strMethod) const;
+		// Upper bounds for tools/call arguments: serialized size in bytes and JSON nesting depth
+		// (the arguments object itself is depth 1). 0 means unlimited, larger arguments fail with ERRNO_INVALID_PARAMS.
+		void SetServerArgumentLimits(unsigned int nMaxBytes, unsigned int nMaxDepth);
+		// Upper bound for the text content of a tools/call result, 0 means unlimited. Oversized results are either
+		// truncated with a marker and _meta.truncatedBytes, or replaced with an isError result.
+		void SetServerMaxResultBytes(unsigned int nMaxBytes, bool bTruncate);
+		int LimitToolResult(MCP::CallToolResult& result) const;
+		// Text added to successful tools/call results that have no content. Empty by default, in which case
+		// such results are sent with an empty content array.
+		void SetServerEmptyResultText(const std::string& strText);
+		std::string GetServerEmptyResultText() const;
+		// How long results of tools/call requests carrying an idempotency key are kept, 0 disables caching.
+		// A key belongs to the tool name and arguments it was first used with, and is held from the moment
+		// the call is queued: reusing it for another call fails with ERRNO_INVALID_PARAMS, a retry while the
+		// call still runs with ERRNO_SERVER_BUSY, and a retry after it finished gets its result replayed.
+		void SetServerIdempotencyTTL(unsigned int nSeconds);
+		int CacheIdempotentResult(const std::shared_ptr<MCP::Request>& spRequest, const MCP::CallToolResult& result);
+		// Frees the key of a call that ends without a result (rejected, failed or cancelled) for a later retry
+		void ReleaseIdempotencyKey(const std::shared_ptr<MCP::Request>& spRequest);
+		// Maximum number of tools/call tasks running at once, 0 means unlimited. Calls beyond the limit wait
+		// in order for a free slot for up to nMaxWaitMs, and fail with ERRNO_SERVER_BUSY once that runs out.
+		// With nMaxWaitMs 0 they fail fast.
+		void SetServerMaxConcurrentCalls(unsigned int nMaxCalls, unsigned int nMaxWaitMs = 0);
+		// Called by tool tasks when they finish, so a call waiting for a free slot starts right away
+		void NotifyAsyncTaskFinished();
+		// How long a connected client has to send initialize, 0 waits forever. Past the window the transport is
+		// disconnected and Run stops, also when it is waiting on a silent stdio client.
+		void SetServerInitializeTimeout(unsigned int nMilliseconds);
+		// How much of a failure error responses reveal to the client, minimal by default. The specific
+		// message and data are written to the transport's error channel whatever the verbosity.
+		void SetServerErrorVerbosity(ErrorVerbosity eVerbosity);
+		ErrorVerbosity GetServerErrorVerbosity() const;
+		// Every tools/call is recorded on spAuditLogger once its outcome is known, including calls that are
+		// rejected or fail. Values of the named arguments are replaced before recording.
+		void SetServerAuditLogger(const std::shared_ptr<CMCPAuditLogger>& spAuditLogger);
+		void SetServerAuditRedactedArguments(const std::vector<std::string>& vecArgumentNames);
+		// Fields redacted wherever the session records tool arguments, see RedactHelper::redact_fields
+		// for how names and dotted paths are matched.
+		void SetServerRedactedFields(const std::vector<std::string>& vecFields);
+		// Receives one entry per undeliverable notification: time, client, method, notification and reason.
+		// Without one, a line is written to the transport's error channel.
+		void SetServerDeadLetterLogger(const std::shared_ptr<CMCPAuditLogger>& spDeadLetterLogger);
+		std::vector<std::string> GetServerRedactedFields() const;
+		// Outcome is one of "ok", "tool_error", "rejected", "failed", "cancelled" or "replayed"
+		void AuditToolCall(const std::shared_ptr<MCP::Request>& spRequest, const std::string& strOutcome, int iCode = ERRNO_OK, const std::string& strMessage = "");
 		MCP::Implementation GetServerInfo() const;
 		MCP::ServerCapabilities GetServerCapabilities() const;
+		std::string GetServerInstructions(const std::string& strClientName = "") const;
 		bool GetServerToolsPagination() const;
+		bool GetServerArgumentCoercion() const;
+		bool GetServerStrictParsing() const;
+		unsigned int GetServerMaxConcurrentCalls() const;
 		std::vector<MCP::Tool> GetServerTools() const;
+		// Checks the registered tools without failing on the first problem: malformed definitions, names that are
+		// empty, too long or use characters outside [A-Za-z0-9_.-], and duplicate names. One "tools[index] name: issue"
+		// entry per problem is added to vecIssues, which is left empty when the tools are fine.
+		void ValidateServerTools(std::vector<std::string>& vecIssues) const;
+		// Locale the client asked for in initialize, used to pick localized tool descriptions
+		std::string GetClientLocale() const;
 		std::shared_ptr<CMCPTransport> GetTransport() const;
 		SessionState GetSessionState() const;
 		std::shared_ptr<MCP::ProcessRequest> GetServerCallToolsTask(const std::string& strToolName);
+		// Whether the async task thread and the initialize watchdog thread exist, both are joined by Terminate
+		bool IsAsyncTaskThreadRunning() const;
+		bool IsInitializeWatchdogRunning() const;
 
 	private:
 		CMCPSession() = default;
 		int ParseMessage(const std::string& strMsg, std::shared_ptr<MCP::Message>& spMsg);
-		int ParseRequest(const std::string& strMsg, std::shared_ptr<MCP::Message>& spMsg);
-		int ParseResponse(const std::string& strMsg, std::shared_ptr<MCP::Message>& spMsg);
-		int ParseNotification(const std::string& strMsg, std::shared_ptr<MCP::Message>& spMsg);
+		int ParseRequest(const Json::Value& jMsg, std::shared_ptr<MCP::Message>& spMsg);
+		int ParseResponse(const Json::Value& jMsg, std::shared_ptr<MCP::Message>& spMsg);
+		int ParseNotification(const Json::Value& jMsg, std::shared_ptr<MCP::Message>& spMsg);
+		static int CheckKnownRequestFields(const Json::Value& jMsg, std::string& strMessage);
 		int ProcessMessage(int iErrCode, const std::shared_ptr<MCP::Message>& spMsg);
 		int ProcessRequest(int iErrCode, const std::shared_ptr<MCP::Message>& spMsg);
 		// Authorization hook: return ERRNO_OK if allowed, otherwise ERRNO_UNAUTHORIZED/ERRNO_FORBIDDEN
@@ -67,13 +204,30 @@ namespace MCP
 		int ProcessResponse(int iErrCode, const std::shared_ptr<MCP::Message>& spMsg);
 		int ProcessNotification(int iErrCode, const std::shared_ptr<MCP::Message>& spMsg);
 		int SwitchState(SessionState eState);
+		static int GetLogLevelRank(const std::string& strLevel);
+		// Replays the cached result of a finished call with the same key, or holds the key for this call
+		int BeginIdempotentCall(const std::shared_ptr<MCP::CallToolRequest>& spRequest, bool& bReplayed, bool& bReserved, std::string& strMessage);
+		const MCP::Tool* FindServerTool(const std::string& strToolName) const;
+		int CheckToolArgumentLimits(const Json::Value& jArguments, std::string& strMessage) const;
+		void DeadLetterNotification(const std::string& strMethod, const std::string& strNotification, const std::string& strReason);
+		std::string GetUtcTimestamp();
+		int CheckToolArgumentTypes(const std::string& strToolName, const Json::Value& jArguments, std::string& strMessage) const;
+		int CheckToolSchemaVersion(const std::shared_ptr<MCP::CallToolRequest>& spRequest, std::string& strMessage) const;
+		int CoerceToolArguments(const std::string& strToolName, Json::Value& jArguments, std::string& strMessage) const;
+		// Fills arguments the client left out from the "default" of their input schema property,
+		// then checks that every "required" argument is present
+		int ApplyToolArgumentDefaults(const std::string& strToolName, Json::Value& jArguments, std::string& strMessage) const;
 
 		// Asynchronous task management
 		int CommitAsyncTask(const std::shared_ptr<MCP::CMCPTask>& spTask);
 		int CancelAsyncTask(const MCP::RequestId& requestId);
+		int RejectAsyncTask(const std::shared_ptr<MCP::CMCPTask>& spTask, int iErrCode);
 		int StartAsyncTaskThread();
 		int StopAsyncTaskThread();
 		int AsyncThreadProc();
+		int StartInitializeWatchdog();
+		int StopInitializeWatchdog();
+		int InitializeWatchdogProc();
 
 		static CMCPSession s_Instance;
 
@@ -82,11 +236,62 @@ namespace MCP
 
 		MCP::Implementation m_serverInfo;
 		MCP::ServerCapabilities m_capabilities;
+		std::string m_strInstructions;
+		std::string m_strInstructionsFile;
+		std::unordered_map<std::string, std::string> m_hashClientInstructions;
+		std::atomic_int m_iLogLevel{ 1 };
 		std::vector<MCP::Tool> m_tools;
+		DuplicateToolPolicy m_eDuplicateToolPolicy{ DuplicateToolPolicy_Keep };
 		bool m_bToolsPagination{ false };
+		bool m_bArgumentCoercion{ false };
+		bool m_bStrictParsing{ false };
+		bool m_bSchemaVersionCheck{ false };
+		bool m_bTimingMeta{ false };
+		std::vector<std::string> m_vecDisabledMethods;
+		Json::Value m_jCapabilitiesOverride;
+		unsigned int m_nMaxArgumentBytes{ 0 };
+		unsigned int m_nMaxArgumentDepth{ 0 };
+		unsigned int m_nMaxResultBytes{ 0 };
+		bool m_bTruncateResult{ true };
+		std::string m_strEmptyResultText;
+		std::atomic_uint m_nMaxConcurrentCalls{ 0 };
+		std::atomic_uint m_nMaxCallWaitMs{ 0 };
+		ErrorVerbosity m_eErrorVerbosity{ ErrorVerbosity_Minimal };
+		std::atomic_uint m_nIdempotencyTTL{ 0 };
+		std::mutex m_mtxIdempotency;
+		struct IdempotentCall
+		{
+			// Tool name and serialized arguments the key was first used with
+			std::string strFingerprint;
+			MCP::RequestId requestId;
+			bool bInFlight{ true };
+			std::chrono::steady_clock::time_point tpExpiry;
+			MCP::CallToolResult result{ false };
+		};
+		std::unordered_map<std::string, IdempotentCall> m_hashIdempotentResults;
+		MCP::Implementation m_clientInfo;
+		Json::Value m_jClientCapabilities{ Json::objectValue };
+		std::string m_strClientLocale;
+		// Server-initiated requests waiting for the client's response, keyed by their string id
+		struct PendingRequest
+		{
+			bool bDone{ false };
+			int iErrCode{ ERRNO_OK };
+			Json::Value jResult;
+		};
+		std::mutex m_mtxPendingRequests;
+		std::condition_variable m_cvPendingRequests;
+		std::unordered_map<std::string, std::shared_ptr<PendingRequest>> m_hashPendingRequests;
+		std::atomic_uint m_nOutgoingRequestId{ 0 };
+		std::shared_ptr<CMCPAuditLogger> m_spAuditLogger;
+		std::vector<std::string> m_vecAuditRedactedArguments;
+		std::vector<std::string> m_vecRedactedFields;
+		std::mutex m_mtxAudit;
+		std::shared_ptr<CMCPAuditLogger> m_spDeadLetterLogger;
 
 		std::unordered_map<MessageCategory, std::vector<std::shared_ptr<MCP::Message>>> m_hashMessage;
 		std::unordered_map<std::string, std::shared_ptr<MCP::ProcessCallToolRequest>> m_hashCallToolsTasks;
+		std::shared_ptr<MCP::ProcessCallToolRequest> m_spFallbackCallToolTask;
 
 		// Asynchronous task management
 		std::unique_ptr<std::thread> m_upTaskThread;
@@ -96,5 +301,18 @@ namespace MCP
 		std::deque<std::shared_ptr<MCP::CMCPTask>> m_deqAsyncTasks;
 		std::vector<MCP::RequestId> m_vecCancelledTaskIds;
 		std::vector<std::shared_ptr<MCP::CMCPTask>> m_vecAsyncTasksCache;
+		// Tasks over the concurrent call limit and the time they may wait until, only used by the async thread
+		std::deque<std::pair<std::chrono::steady_clock::time_point, std::shared_ptr<MCP::CMCPTask>>> m_deqWaitingTasks;
+		// Set under m_mtxAsyncThread when a running task finished since the async thread last woke up
+		bool m_bAsyncTaskFinished{ false };
+
+		// Disconnects clients that never send initialize
+		std::atomic_uint m_nInitializeTimeoutMs{ 0 };
+		std::unique_ptr<std::thread> m_upInitializeWatchdog;
+		std::mutex m_mtxInitializeWatchdog;
+		std::condition_variable m_cvInitializeWatchdog;
+		bool m_bInitializeReceived{ false };
+		bool m_bStopInitializeWatchdog{ false };
+		std::atomic_bool m_bInitializeTimedOut{ false };
 	};
 }
\ No newline at end of file
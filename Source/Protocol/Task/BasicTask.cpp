@@ -1,6 +1,7 @@
 #include "BasicTask.h"
 #include "../Session/Session.h"
 #include "../Message/Notification.h"
+#include "../Public/ErrorHelper.h"
 
 namespace MCP
 {
@@ -46,6 +47,14 @@ namespace MCP
 
 	////////////////////////////////////////////////////////////////////////////////////////
 	// ProcessErrorRequest
+	bool ProcessErrorRequest::IsValid() const
+	{
+		if (!m_spRequest)
+			return false;
+
+		return m_spRequest->requestId.IsValid();
+	}
+
 	std::shared_ptr<CMCPTask> ProcessErrorRequest::Clone() const
 	{
 		return nullptr;
@@ -60,42 +69,41 @@ namespace MCP
 		if (!spErrorResponse)
 			return ERRNO_INTERNAL_ERROR;
 
+		auto spTransport = CMCPSession::GetInstance().GetTransport();
+		if (!spTransport)
+			return ERRNO_INTERNAL_ERROR;
+
+		m_iCode = ErrorHelper::to_response_code(m_iCode);
+		const std::string strDefaultMessage = ErrorHelper::get_message(m_iCode);
 		if (m_strMessage.empty())
+			m_strMessage = strDefaultMessage;
+		if (m_strMessage != strDefaultMessage || !m_jData.isNull())
 		{
-			switch (m_iCode)
+			std::string strLog = u8"error " + std::to_string(m_iCode) + u8": " + m_strMessage;
+			if (!m_jData.isNull())
 			{
-				case ERRNO_PARSE_ERROR:
-				{
-					m_strMessage = ERROR_MESSAGE_PARSE_ERROR;
-				} break;
-				case ERRNO_INVALID_REQUEST:
-				{
-					m_strMessage = ERROR_MESSAGE_INVALID_REQUEST;
-				} break;
-				case ERRNO_METHOD_NOT_FOUND:
-				{
-					m_strMessage = ERROR_MESSAGE_METHOD_NOT_FOUND;
-				} break;
-				case ERRNO_INVALID_PARAMS:
-				{
-					m_strMessage = ERROR_MESSAGE_INVALID_PARAMS;
-				} break;
-				case ERRNO_INTERNAL_ERROR:
-				{
-					m_strMessage = ERROR_MESSAGE_INTERNAL_ERROR;
-				} break;
-				default: break;
+				Json::FastWriter writer;
+				writer.omitEndingLineFeed();
+				strLog += u8" data: " + writer.write(m_jData);
+			}
+			spTransport->Error(strLog);
+
+			// What the client did wrong stays in the message, server-side failures are only described server side
+			if (CMCPSession::ErrorVerbosity_Minimal == CMCPSession::GetInstance().GetServerErrorVerbosity())
+			{
+				if (!ErrorHelper::is_client_error(m_iCode))
+					m_strMessage = strDefaultMessage.empty() ? ERROR_MESSAGE_INTERNAL_ERROR : strDefaultMessage;
+				m_jData = Json::Value();
 			}
 		}
+		spErrorResponse->requestId = m_spRequest->requestId;
 		spErrorResponse->iCode = m_iCode;
 		spErrorResponse->strMesage = m_strMessage;
+		spErrorResponse->jData = m_jData;
 
 		std::string strResponse;
 		if (ERRNO_OK != spErrorResponse->Serialize(strResponse))
 			return ERRNO_INTERNAL_ERROR;
-		auto spTransport = CMCPSession::GetInstance().GetTransport();
-		if (!spTransport)
-			return ERRNO_INTERNAL_ERROR;
 		if (ERRNO_OK != spTransport->Write(strResponse))
 			return ERRNO_INTERNAL_ERROR;
 
@@ -112,6 +120,11 @@ namespace MCP
 		m_strMessage = strMessage;
 	}
 
+	void ProcessErrorRequest::SetErrorData(const Json::Value& jData)
+	{
+		m_jData = jData;
+	}
+
 	////////////////////////////////////////////////////////////////////////////////////////
 	// ProcessInitializeRequest
 	std::shared_ptr<CMCPTask> ProcessInitializeRequest::Clone() const
@@ -130,7 +143,10 @@ namespace MCP
 		spInitializeResult->requestId = m_spRequest->requestId;
 		spInitializeResult->strProtocolVersion = PROTOCOL_VER;
 		spInitializeResult->capabilities = CMCPSession::GetInstance().GetServerCapabilities();
+		spInitializeResult->jCapabilitiesOverride = CMCPSession::GetInstance().GetServerCapabilitiesOverride();
 		spInitializeResult->implServerInfo = CMCPSession::GetInstance().GetServerInfo();
+		auto spInitializeRequest = std::dynamic_pointer_cast<InitializeRequest>(m_spRequest);
+		spInitializeResult->strInstructions = CMCPSession::GetInstance().GetServerInstructions(spInitializeRequest ? spInitializeRequest->clientInfo.strName : "");
 		std::string strResponse;
 		if (ERRNO_OK != spInitializeResult->Serialize(strResponse))
 			return ERRNO_INTERNAL_ERROR;
@@ -196,8 +212,9 @@ namespace MCP
 					auto spErrorResponse = std::make_shared<ErrorResponse>(true);
 					if (!spErrorResponse)
 						return ERRNO_INTERNAL_ERROR;
+					spErrorResponse->requestId = spListToolRequest->requestId;
 					spErrorResponse->iCode = ERRNO_INVALID_PARAMS;
-					spErrorResponse->strMesage = "invalid params";
+					spErrorResponse->strMesage = ErrorHelper::get_message(ERRNO_INVALID_PARAMS);
 					if (ERRNO_OK != spErrorResponse->Serialize(strResponse))
 						return ERRNO_INTERNAL_ERROR;
 				}
@@ -240,6 +257,9 @@ namespace MCP
 
 		if (spListToolsResult)
 		{
+			auto strLocale = CMCPSession::GetInstance().GetClientLocale();
+			for (auto& tool : spListToolsResult->vecTools)
+				tool.strDescription = tool.GetLocalizedDescription(strLocale);
 			if (ERRNO_OK != spListToolsResult->Serialize(strResponse))
 				return ERRNO_INTERNAL_ERROR;
 		}
@@ -258,6 +278,27 @@ namespace MCP
 
 	////////////////////////////////////////////////////////////////////////////////////////
 	// ProcessCallToolRequest
+	ProcessCallToolRequest::ProcessCallToolRequest(const ProcessCallToolRequest& other)
+		: ProcessRequest(other)
+	{
+		*this = other;
+	}
+
+	ProcessCallToolRequest& ProcessCallToolRequest::operator=(const ProcessCallToolRequest& other)
+	{
+		if (this == &other)
+			return *this;
+
+		ProcessRequest::operator=(other);
+		m_bFinished = other.m_bFinished.load();
+		m_bCancelled = other.m_bCancelled.load();
+		m_tpReceived = other.m_tpReceived;
+		m_tpQueued = other.m_tpQueued;
+		m_tpStarted = other.m_tpStarted;
+
+		return *this;
+	}
+
 	bool ProcessCallToolRequest::IsFinished() const
 	{
 		return m_bFinished;
@@ -375,6 +416,13 @@ namespace MCP
 		return m_bCancelled;
 	}
 
+	int ProcessCallToolRequest::Cancel()
+	{
+		m_bCancelled = true;
+
+		return ERRNO_OK;
+	}
+
 	std::shared_ptr<MCP::CallToolResult> ProcessCallToolRequest::BuildResult()
 	{
 		if (!IsValid())
@@ -384,15 +432,33 @@ namespace MCP
 		if (!spCallToolResult)
 			return nullptr;
 		spCallToolResult->requestId = m_spRequest->requestId;
+		auto spCallToolRequest = std::dynamic_pointer_cast<CallToolRequest>(m_spRequest);
+		if (spCallToolRequest)
+			spCallToolResult->jMeta = spCallToolRequest->GetEchoedMeta();
 
 		return spCallToolResult;
 	}
 
+	void ProcessCallToolRequest::MarkQueued(const std::chrono::steady_clock::time_point& tpReceived)
+	{
+		m_tpReceived = tpReceived;
+		m_tpQueued = std::chrono::steady_clock::now();
+		m_tpStarted = m_tpQueued;
+	}
+
+	void ProcessCallToolRequest::MarkStarted()
+	{
+		m_tpStarted = std::chrono::steady_clock::now();
+	}
+
 	int ProcessCallToolRequest::NotifyProgress(int iProgress, int iTotal)
 	{
 		if (!m_spRequest)
 			return ERRNO_INTERNAL_ERROR;
 
+		if (m_bCancelled)
+			return ERRNO_OK;
+
 		if (m_spRequest->progressToken.IsValid())
 		{
 			MCP::ProgressNotification progressNotification(false);
@@ -401,13 +467,7 @@ namespace MCP
 			progressNotification.iProgress = iProgress;
 			progressNotification.iTotal = iTotal;
 
-			std::string strNotification;
-			if (ERRNO_OK != progressNotification.Serialize(strNotification))
-				return ERRNO_INTERNAL_ERROR;
-			auto spTransport = CMCPSession::GetInstance().GetTransport();
-			if (!spTransport)
-				return ERRNO_INTERNAL_ERROR;
-			if (ERRNO_OK != spTransport->Write(strNotification))
+			if (ERRNO_OK != CMCPSession::GetInstance().WriteNotification(progressNotification))
 				return ERRNO_INTERNAL_ERROR;
 		}
 
@@ -416,11 +476,58 @@ namespace MCP
 
 	int ProcessCallToolRequest::NotifyResult(std::shared_ptr<MCP::CallToolResult> spResult)
 	{
+		// Read once, a cancel arriving later no longer stops the response
+		const bool bCancelled = m_bCancelled.load();
 		m_bFinished = true;
+		CMCPSession::GetInstance().NotifyAsyncTaskFinished();
 
 		if (!spResult)
 			return ERRNO_INTERNAL_ERROR;
 
+		// Per the spec, no response is sent for a request the client has cancelled
+		if (bCancelled)
+		{
+			CMCPSession::GetInstance().AuditToolCall(m_spRequest, u8"cancelled");
+			CMCPSession::GetInstance().ReleaseIdempotencyKey(m_spRequest);
+			return ERRNO_OK;
+		}
+		if (!spResult->bIsError && spResult->vecTextContent.empty() && spResult->vecImageContent.empty() && spResult->vecEmbeddedResource.empty()
+			&& spResult->vecResourceLinks.empty())
+		{
+			auto strEmptyResultText = CMCPSession::GetInstance().GetServerEmptyResultText();
+			if (!strEmptyResultText.empty())
+			{
+				MCP::TextContent textContent;
+				textContent.strType = CONST_TEXT;
+				textContent.strText = strEmptyResultText;
+				spResult->vecTextContent.push_back(textContent);
+			}
+		}
+		CMCPSession::GetInstance().LimitToolResult(*spResult);
+		CMCPSession::GetInstance().AuditToolCall(m_spRequest, spResult->bIsError ? u8"tool_error" : u8"ok");
+
+		if (spResult->jMeta.isObject())
+		{
+			auto tpFinished = std::chrono::steady_clock::now();
+			auto fnMilliseconds = [](const std::chrono::steady_clock::time_point& tpFrom, const std::chrono::steady_clock::time_point& tpTo)
+			{
+				return static_cast<Json::Int64>(std::chrono::duration_cast<std::chrono::milliseconds>(tpTo - tpFrom).count());
+			};
+			if (!spResult->jMeta.isMember("durationMs"))
+				spResult->jMeta["durationMs"] = fnMilliseconds(m_tpReceived, tpFinished);
+			if (CMCPSession::GetInstance().GetServerTimingMeta() && !spResult->jMeta.isMember("timing"))
+			{
+				Json::Value jTiming(Json::objectValue);
+				jTiming["validationMs"] = fnMilliseconds(m_tpReceived, m_tpQueued);
+				jTiming["queueMs"] = fnMilliseconds(m_tpQueued, m_tpStarted);
+				jTiming["executeMs"] = fnMilliseconds(m_tpStarted, tpFinished);
+				jTiming["totalMs"] = fnMilliseconds(m_tpReceived, tpFinished);
+				spResult->jMeta["timing"] = jTiming;
+			}
+		}
+
+		CMCPSession::GetInstance().CacheIdempotentResult(m_spRequest, *spResult);
+
 		std::string strResponse;
 		if (ERRNO_OK != spResult->Serialize(strResponse))
 			return ERRNO_INTERNAL_ERROR;
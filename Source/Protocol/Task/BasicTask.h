@@ -6,6 +6,8 @@
 #include "../Message/Request.h"
 #include "../Message/Response.h"
 #include <memory>
+#include <chrono>
+#include <atomic>
 
 namespace MCP
 {
@@ -39,15 +41,19 @@ namespace MCP
 
 		}
 
+		// Only the request id is needed to answer with an error, the request itself may be malformed
+		bool IsValid() const override;
 		std::shared_ptr<CMCPTask> Clone() const override;
 		int Execute() override;
 
 		void SetErrorCode(int iCode);
 		void SetErrorMessage(const std::string& strMessage);
+		void SetErrorData(const Json::Value& jData);
 
 	private:
 		int m_iCode{ 0 };
 		std::string m_strMessage;
+		Json::Value m_jData;
 	};
 
 	class ProcessInitializeRequest : public ProcessRequest
@@ -123,15 +129,29 @@ namespace MCP
 		{
 
 		}
+		// Clones are made by copy assignment, which the atomic flags would otherwise rule out
+		ProcessCallToolRequest(const ProcessCallToolRequest& other);
+		ProcessCallToolRequest& operator=(const ProcessCallToolRequest& other);
 
 		bool IsFinished() const override;
 		bool IsCancelled() const override;
+		// Derived tasks that override Cancel() must call this to mark the request as cancelled.
+		int Cancel() override;
 		std::shared_ptr<MCP::CallToolResult> BuildResult();
 		int NotifyProgress(int iProgress, int iTotal);
 		int NotifyResult(std::shared_ptr<MCP::CallToolResult> spResult);
+		// Set by the session when the validated call is queued and when its Execute starts,
+		// NotifyResult reports them as _meta.durationMs and, when enabled, _meta.timing.
+		void MarkQueued(const std::chrono::steady_clock::time_point& tpReceived);
+		void MarkStarted();
 
 	private:
-		bool m_bFinished{ false };
-		bool m_bCancelled{ false };
+		// Set by the worker thread in NotifyResult, read by the session's async thread
+		std::atomic_bool m_bFinished{ false };
+		// Set by the session's async thread in Cancel, read by the worker thread
+		std::atomic_bool m_bCancelled{ false };
+		std::chrono::steady_clock::time_point m_tpReceived{ std::chrono::steady_clock::now() };
+		std::chrono::steady_clock::time_point m_tpQueued{ m_tpReceived };
+		std::chrono::steady_clock::time_point m_tpStarted{ m_tpReceived };
 	};
 }
\ No newline at end of file
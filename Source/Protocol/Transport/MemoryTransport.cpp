@@ -0,0 +1,85 @@
+#include "MemoryTransport.h"
+#include "../Public/PublicDef.h"
+
+namespace MCP
+{
+	int CMemoryTransport::Connect()
+	{
+		const std::lock_guard<std::mutex> _lock(m_mtxQueue);
+		m_bInputClosed = false;
+
+		return ERRNO_OK;
+	}
+
+	int CMemoryTransport::Disconnect()
+	{
+		CloseInput();
+
+		return ERRNO_OK;
+	}
+
+	int CMemoryTransport::Read(std::string& strOut)
+	{
+		std::unique_lock<std::mutex> _lock(m_mtxQueue);
+		m_cvInput.wait(_lock, [this]() { return !m_deqInput.empty() || m_bInputClosed; });
+		if (m_deqInput.empty())
+			return ERRNO_INTERNAL_INPUT_TERMINATE;
+
+		strOut = m_deqInput.front();
+		m_deqInput.pop_front();
+
+		return ERRNO_OK;
+	}
+
+	int CMemoryTransport::Write(const std::string& strIn)
+	{
+		std::unique_lock<std::mutex> _lock(m_mtxQueue);
+		m_deqOutput.push_back(strIn);
+		_lock.unlock();
+		m_cvOutput.notify_all();
+
+		return ERRNO_OK;
+	}
+
+	int CMemoryTransport::Error(const std::string& strIn)
+	{
+		const std::lock_guard<std::mutex> _lock(m_mtxQueue);
+		m_vecErrors.push_back(strIn);
+
+		return ERRNO_OK;
+	}
+
+	void CMemoryTransport::PushInput(const std::string& strMsg)
+	{
+		std::unique_lock<std::mutex> _lock(m_mtxQueue);
+		m_deqInput.push_back(strMsg);
+		_lock.unlock();
+		m_cvInput.notify_one();
+	}
+
+	void CMemoryTransport::CloseInput()
+	{
+		std::unique_lock<std::mutex> _lock(m_mtxQueue);
+		m_bInputClosed = true;
+		_lock.unlock();
+		m_cvInput.notify_all();
+	}
+
+	bool CMemoryTransport::PopOutput(std::string& strMsg, std::chrono::milliseconds timeout)
+	{
+		std::unique_lock<std::mutex> _lock(m_mtxQueue);
+		if (!m_cvOutput.wait_for(_lock, timeout, [this]() { return !m_deqOutput.empty(); }))
+			return false;
+
+		strMsg = m_deqOutput.front();
+		m_deqOutput.pop_front();
+
+		return true;
+	}
+
+	std::vector<std::string> CMemoryTransport::GetErrors() const
+	{
+		const std::lock_guard<std::mutex> _lock(m_mtxQueue);
+		return m_vecErrors;
+	}
+}
@@ -0,0 +1,40 @@
+#pragma once
+// In-memory transport, mainly for tests.
+// Messages pushed with PushInput are read by the session as if they came from a client,
+// everything the session writes can be collected with PopOutput.
+
+#include <string>
+#include <deque>
+#include <vector>
+#include <chrono>
+#include <mutex>
+#include <condition_variable>
+#include "Transport.h"
+
+namespace MCP
+{
+	class CMemoryTransport : public CMCPTransport
+	{
+	public:
+		int Connect() override;
+		int Disconnect() override;
+		int Read(std::string& strOut) override;
+		int Write(const std::string& strIn) override;
+		int Error(const std::string& strIn) override;
+
+		void PushInput(const std::string& strMsg);
+		// Once the pending input is drained, Read reports the end of input and the session stops.
+		void CloseInput();
+		bool PopOutput(std::string& strMsg, std::chrono::milliseconds timeout);
+		std::vector<std::string> GetErrors() const;
+
+	private:
+		mutable std::mutex m_mtxQueue;
+		std::condition_variable m_cvInput;
+		std::condition_variable m_cvOutput;
+		std::deque<std::string> m_deqInput;
+		std::deque<std::string> m_deqOutput;
+		std::vector<std::string> m_vecErrors;
+		bool m_bInputClosed{ false };
+	};
+}
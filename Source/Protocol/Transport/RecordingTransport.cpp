@@ -0,0 +1,118 @@
+#include "RecordingTransport.h"
+#include "../Public/PublicDef.h"
+#include "../Public/RedactHelper.h"
+#include <json/json.h>
+
+namespace MCP
+{
+	CRecordingTransport::CRecordingTransport(const std::shared_ptr<CMCPTransport>& spTransport, const std::string& strPath,
+		const std::vector<std::string>& vecRedactedArguments)
+		: m_spTransport(spTransport)
+		, m_vecRedactedArguments(vecRedactedArguments)
+		, m_ofsFile(strPath, std::ios::out | std::ios::app)
+	{
+
+	}
+
+	int CRecordingTransport::Connect()
+	{
+		if (!m_spTransport)
+			return ERRNO_INTERNAL_ERROR;
+
+		return m_spTransport->Connect();
+	}
+
+	int CRecordingTransport::Disconnect()
+	{
+		if (!m_spTransport)
+			return ERRNO_INTERNAL_ERROR;
+
+		return m_spTransport->Disconnect();
+	}
+
+	int CRecordingTransport::Read(std::string& strOut)
+	{
+		if (!m_spTransport)
+			return ERRNO_INTERNAL_ERROR;
+
+		int iErrCode = m_spTransport->Read(strOut);
+		if (ERRNO_OK == iErrCode)
+			Record("in", strOut);
+
+		return iErrCode;
+	}
+
+	int CRecordingTransport::Write(const std::string& strIn)
+	{
+		if (!m_spTransport)
+			return ERRNO_INTERNAL_ERROR;
+
+		Record("out", strIn);
+
+		return m_spTransport->Write(strIn);
+	}
+
+	int CRecordingTransport::Error(const std::string& strIn)
+	{
+		if (!m_spTransport)
+			return ERRNO_INTERNAL_ERROR;
+
+		return m_spTransport->Error(strIn);
+	}
+
+	void CRecordingTransport::Record(const char* lpcszDirection, const std::string& strMsg)
+	{
+		Json::Value jEntry(Json::objectValue);
+		jEntry["direction"] = lpcszDirection;
+
+		// Messages that are not valid JSON are kept verbatim, they may be what needs reproducing
+		Json::Reader reader;
+		Json::Value jMsg;
+		if (reader.parse(strMsg, jMsg) && jMsg.isObject())
+		{
+			if (jMsg.isMember(MSG_KEY_METHOD) && jMsg[MSG_KEY_METHOD] == METHOD_TOOLS_CALL
+				&& jMsg.isMember(MSG_KEY_PARAMS) && jMsg[MSG_KEY_PARAMS].isObject()
+				&& jMsg[MSG_KEY_PARAMS].isMember(MSG_KEY_ARGUMENTS) && jMsg[MSG_KEY_PARAMS][MSG_KEY_ARGUMENTS].isObject())
+			{
+				RedactHelper::redact_fields(jMsg[MSG_KEY_PARAMS][MSG_KEY_ARGUMENTS], m_vecRedactedArguments);
+			}
+			jEntry[MSG_KEY_MESSAGE] = jMsg;
+		}
+		else
+		{
+			jEntry[MSG_KEY_MESSAGE] = strMsg;
+		}
+
+		Json::FastWriter writer;
+		std::string strLine = writer.write(jEntry);
+
+		const std::lock_guard<std::mutex> _lock(m_mtxFile);
+		m_ofsFile << strLine;
+		m_ofsFile.flush();
+	}
+
+	int CRecordingTransport::LoadRecordedInput(const std::string& strPath, std::vector<std::string>& vecMessages)
+	{
+		std::ifstream ifsFile(strPath);
+		if (!ifsFile)
+			return ERRNO_INTERNAL_INPUT_ERROR;
+
+		Json::Reader reader;
+		Json::FastWriter writer;
+		std::string strLine;
+		while (std::getline(ifsFile, strLine))
+		{
+			Json::Value jEntry;
+			if (!reader.parse(strLine, jEntry) || !jEntry.isObject() || jEntry["direction"] != "in")
+				continue;
+
+			auto& jMsg = jEntry[MSG_KEY_MESSAGE];
+			std::string strMsg = jMsg.isString() ? jMsg.asString() : writer.write(jMsg);
+			if (!strMsg.empty() && '\n' == strMsg.back())
+				strMsg.pop_back();
+			vecMessages.push_back(strMsg);
+		}
+
+		return ERRNO_OK;
+	}
+}
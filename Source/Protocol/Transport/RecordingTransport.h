@@ -0,0 +1,40 @@
+#pragma once
+// Transport decorator that records the exchanged messages, for reproducing a client's session.
+// Every message read from or written to the wrapped transport is appended to a file as a JSON line
+// {"direction":"in"|"out","message":...}; the error channel is passed through unrecorded.
+
+#include <string>
+#include <vector>
+#include <memory>
+#include <fstream>
+#include <mutex>
+#include "Transport.h"
+
+namespace MCP
+{
+	class CRecordingTransport : public CMCPTransport
+	{
+	public:
+		// Values of the named tools/call arguments are replaced before recording, e.g. with
+		// CMCPSession::GetServerRedactedFields() to redact the same fields as the audit log
+		CRecordingTransport(const std::shared_ptr<CMCPTransport>& spTransport, const std::string& strPath,
+			const std::vector<std::string>& vecRedactedArguments = {});
+
+		int Connect() override;
+		int Disconnect() override;
+		int Read(std::string& strOut) override;
+		int Write(const std::string& strIn) override;
+		int Error(const std::string& strIn) override;
+
+		// Reads back the incoming messages of a recording, in order, e.g. to feed a CMemoryTransport
+		static int LoadRecordedInput(const std::string& strPath, std::vector<std::string>& vecMessages);
+
+	private:
+		void Record(const char* lpcszDirection, const std::string& strMsg);
+
+		std::shared_ptr<CMCPTransport> m_spTransport;
+		std::vector<std::string> m_vecRedactedArguments;
+		std::mutex m_mtxFile;
+		std::ofstream m_ofsFile;
+	};
+}
@@ -1,40 +1,93 @@
 #include "Transport.h"
 #include "../Public/PublicDef.h"
 #include <iostream>
+#include <thread>
 
 namespace MCP
 {
+	CStdioTransport::~CStdioTransport()
+	{
+		Disconnect();
+	}
+
 	int CStdioTransport::Connect()
 	{
+		{
+			const std::lock_guard<std::mutex> _lock(m_spStdin->mtx);
+			m_spStdin->bDisconnected = false;
+		}
+		StartStdinReader();
+
 		return ERRNO_OK;
 	}
 
 	int CStdioTransport::Disconnect()
 	{
+		{
+			const std::lock_guard<std::mutex> _lock(m_spStdin->mtx);
+			m_spStdin->bDisconnected = true;
+			m_spStdin->deqLines.clear();
+		}
+		m_spStdin->cv.notify_all();
+
 		return ERRNO_OK;
 	}
 
+	void CStdioTransport::StartStdinReader()
+	{
+		const std::lock_guard<std::mutex> _lock(m_spStdin->mtx);
+		if (m_spStdin->bReading || ERRNO_OK != m_spStdin->iEndCode)
+			return;
+		m_spStdin->bReading = true;
+
+		auto spStdin = m_spStdin;
+		std::thread([spStdin]()
+		{
+			while (true)
+			{
+				std::string strLine;
+				bool bRead = static_cast<bool>(std::getline(std::cin, strLine));
+				{
+					const std::lock_guard<std::mutex> _lock(spStdin->mtx);
+					if (bRead)
+					{
+						spStdin->deqLines.push_back(std::move(strLine));
+					}
+					else
+					{
+						spStdin->iEndCode = std::cin.eof() ? ERRNO_INTERNAL_INPUT_TERMINATE : ERRNO_INTERNAL_INPUT_ERROR;
+						spStdin->bReading = false;
+					}
+				}
+				spStdin->cv.notify_all();
+				if (!bRead)
+					break;
+			}
+		}).detach();
+	}
+
 	int CStdioTransport::Read(std::string& strOut)
 	{
 		const std::lock_guard<std::recursive_mutex> _lock(m_mtxStdin);
 
-		if (std::getline(std::cin, strOut))
+		// Transports that are read without Connect start reading on the first call
+		StartStdinReader();
+
+		std::unique_lock<std::mutex> _lockLines(m_spStdin->mtx);
+		m_spStdin->cv.wait(_lockLines, [this]()
 		{
-			return ERRNO_OK;
-		}
-		else
+			return m_spStdin->bDisconnected || !m_spStdin->deqLines.empty() || ERRNO_OK != m_spStdin->iEndCode;
+		});
+		if (m_spStdin->bDisconnected)
+			return ERRNO_INTERNAL_INPUT_TERMINATE;
+		if (!m_spStdin->deqLines.empty())
 		{
-			if (std::cin.eof())
-			{
-				return ERRNO_INTERNAL_INPUT_TERMINATE;
-			}
-			else
-			{
-				return ERRNO_INTERNAL_INPUT_ERROR;
-			}
+			strOut = std::move(m_spStdin->deqLines.front());
+			m_spStdin->deqLines.pop_front();
+			return ERRNO_OK;
 		}
 
-		return ERRNO_INTERNAL_ERROR;
+		return m_spStdin->iEndCode;
 	}
 
 	int CStdioTransport::Write(const std::string& strIn)
@@ -47,6 +100,9 @@ namespace MCP
 
 	int CStdioTransport::Error(const std::string& strIn)
 	{
+		const std::lock_guard<std::recursive_mutex> _lock(m_mtxStderr);
+		std::cerr << strIn << std::endl;
+
 		return ERRNO_OK;
 	}
 }
\ No newline at end of file
@@ -4,6 +4,9 @@
 
 #include <string>
 #include <mutex>
+#include <memory>
+#include <deque>
+#include <condition_variable>
 #include "../Public/PublicDef.h"
 
 namespace MCP
@@ -20,9 +23,13 @@ namespace MCP
 		virtual int Error(const std::string& strIn) = 0;
 	};
 
+	// std::cin is read on a thread of its own, so Disconnect can end a Read that is waiting for the client.
+	// The thread lives until std::cin ends, lines it reads after a Disconnect go to the next Connect.
 	class CStdioTransport : public CMCPTransport
 	{
 	public:
+		~CStdioTransport() override;
+
 		int Connect() override;
 		int Disconnect() override;
 		int Read(std::string& strOut) override;
@@ -30,6 +37,20 @@ namespace MCP
 		int Error(const std::string& strIn) override;
 
 	private:
+		// Shared with the reading thread, which may outlive the transport
+		struct StdinLines
+		{
+			std::mutex mtx;
+			std::condition_variable cv;
+			std::deque<std::string> deqLines;
+			bool bReading{ false };
+			bool bDisconnected{ false };
+			// Set once std::cin ends, ERRNO_INTERNAL_INPUT_TERMINATE or ERRNO_INTERNAL_INPUT_ERROR
+			int iEndCode{ ERRNO_OK };
+		};
+		void StartStdinReader();
+
+		std::shared_ptr<StdinLines> m_spStdin{ std::make_shared<StdinLines>() };
 		std::recursive_mutex m_mtxStdin;
 		std::recursive_mutex m_mtxStdout;
 		std::recursive_mutex m_mtxStderr;
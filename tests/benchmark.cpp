@@ -0,0 +1,97 @@
+// Micro-benchmark of the tools/call hot path: a session on CMemoryTransport answers calls to a tool
+// whose task replies at once. For reference it also times JSON parses of the same request line,
+// each incoming message costs one of them.
+#include <iostream>
+#include <string>
+#include <chrono>
+#include <cstdlib>
+#include "Public/PublicDef.h"
+#include "Message/BasicMessage.h"
+#include "Session/Session.h"
+#include "Transport/MemoryTransport.h"
+#include "Task/BasicTask.h"
+
+class CReplyTask : public MCP::ProcessCallToolRequest
+{
+public:
+    CReplyTask(const std::shared_ptr<MCP::Request>& spRequest)
+        : ProcessCallToolRequest(spRequest)
+    {
+
+    }
+
+    std::shared_ptr<CMCPTask> Clone() const override
+    {
+        auto spClone = std::make_shared<CReplyTask>(nullptr);
+        if (spClone)
+            *spClone = *this;
+        return spClone;
+    }
+
+    int Execute() override
+    {
+        auto spResult = BuildResult();
+        if (!spResult)
+            return MCP::ERRNO_INTERNAL_ERROR;
+        MCP::TextContent text;
+        text.strType = MCP::CONST_TEXT;
+        text.strText = "ok";
+        spResult->vecTextContent.push_back(text);
+        return NotifyResult(spResult);
+    }
+};
+
+static double MicrosecondsPer(const std::chrono::steady_clock::time_point& tpStart, int iIterations)
+{
+    return std::chrono::duration<double, std::micro>(std::chrono::steady_clock::now() - tpStart).count() / iIterations;
+}
+
+int main(int argc, char* argv[])
+{
+    const int iIterations = argc > 1 ? std::atoi(argv[1]) : 20000;
+    if (iIterations <= 0)
+        return 1;
+
+    auto spTransport = std::make_shared<MCP::CMemoryTransport>();
+    auto& session = MCP::CMCPSession::GetInstance();
+    session.SetTransport(spTransport);
+    MCP::Tool tool;
+    tool.strName = "reply";
+    Json::Reader().parse(R"({"type":"object","properties":{"device":{"type":"string"},"level":{"type":"integer"}}})", tool.jInputSchema);
+    session.SetServerTools({ tool });
+    session.SetServerCallToolsTasks({ { "reply", std::make_shared<CReplyTask>(nullptr) } });
+
+    std::string strOut;
+    const auto timeout = std::chrono::seconds(2);
+    if (MCP::ERRNO_OK != session.Ready())
+        return 1;
+    session.Dispatch(R"({"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"benchmark","version":"1.0"}}})");
+    session.Dispatch(R"({"jsonrpc":"2.0","method":"notifications/initialized"})");
+    if (!spTransport->PopOutput(strOut, timeout))
+        return 1;
+
+    const std::string strCall = R"({"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"reply","arguments":{"device":"relay-1","level":3}}})";
+    auto tpStart = std::chrono::steady_clock::now();
+    for (int i = 0; i < iIterations; ++i)
+    {
+        session.Dispatch(strCall);
+        if (!spTransport->PopOutput(strOut, timeout))
+        {
+            std::cerr << "no result for call " << i << std::endl;
+            return 1;
+        }
+    }
+    double dCall = MicrosecondsPer(tpStart, iIterations);
+
+    Json::Value jParsed;
+    tpStart = std::chrono::steady_clock::now();
+    for (int i = 0; i < iIterations; ++i)
+        Json::Reader().parse(strCall, jParsed);
+    double dParse = MicrosecondsPer(tpStart, iIterations);
+
+    std::cout << "tools/call round trip: " << dCall << " us" << std::endl;
+    std::cout << "one JSON parse of the request: " << dParse << " us" << std::endl;
+
+    session.Terminate();
+    return 0;
+}
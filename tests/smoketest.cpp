@@ -1,9 +1,1460 @@
 #include <iostream>
-#include "Source/Protocol/Public/PublicDef.h"
+#include <string>
+#include <thread>
+#include <chrono>
+#include "Public/PublicDef.h"
+#include "Public/StringHelper.h"
+#include "Public/ErrorHelper.h"
+#include "Public/RedactHelper.h"
+#include "Message/Response.h"
+#include "Message/BasicMessage.h"
+#include "Message/Request.h"
+#include "Session/Session.h"
+#include "Transport/MemoryTransport.h"
+#include "Transport/RecordingTransport.h"
+#include "Task/BasicTask.h"
+#include "Entity/Server.h"
+#include "Public/Config.h"
+#include <cstdio>
+#include <fstream>
+#include <vector>
+#include <deque>
+#include <mutex>
+#include <atomic>
+#include <streambuf>
+#include <condition_variable>
+
+static int g_iFailures = 0;
+
+static void Expect(bool bCondition, const std::string& strWhat)
+{
+    if (!bCondition)
+    {
+        std::cerr << "FAILED: " << strWhat << std::endl;
+        ++g_iFailures;
+    }
+}
+
+// Minimal server to reach the protected parts of CMCPServer
+class CSmoketestServer : public MCP::CMCPServer<CSmoketestServer>
+{
+public:
+    int Initialize() override
+    {
+        MCP::Implementation info;
+        info.strName = "smoketest";
+        info.strVersion = "1.0";
+        SetServerInfo(info);
+        RegisterServerToolsCapabilities(MCP::Tools());
+        return MCP::ERRNO_OK;
+    }
+
+    static CSmoketestServer& Get()
+    {
+        return s_Instance;
+    }
+
+    using MCP::CMCPServer<CSmoketestServer>::LogStartupSummary;
+    using MCP::CMCPServer<CSmoketestServer>::RegisterServerLoggingCapabilities;
+
+private:
+    friend class MCP::CMCPServer<CSmoketestServer>;
+    CSmoketestServer() = default;
+    static CSmoketestServer s_Instance;
+};
+CSmoketestServer CSmoketestServer::s_Instance;
+
+static void TestPingResultShape()
+{
+    MCP::PingResult ping(false);
+    ping.requestId.eIdDataType = MCP::DataType_Integer;
+    ping.requestId.iId = 7;
+
+    std::string strOut;
+    Expect(MCP::ERRNO_OK == ping.Serialize(strOut), "ping result serializes");
+    Expect(strOut == "{\"id\":7,\"jsonrpc\":\"2.0\",\"result\":{}}\n", "ping result is an empty object: " + strOut);
+}
+
+static void TestExpandVariables()
+{
+    auto fnResolve = [](const std::string& strName, std::string& strValue)
+    {
+        if (strName != "DEVICE")
+            return false;
+        strValue = "dev-1";
+        return true;
+    };
+
+    Expect(MCP::StringHelper::expand_variables("on ${DEVICE}!", fnResolve) == "on dev-1!", "known variable is expanded");
+    Expect(MCP::StringHelper::expand_variables("${OTHER} ${DEVICE", fnResolve) == "${OTHER} ${DEVICE", "unknown and unterminated variables stay literal");
+}
+
+static void TestToolTagsRoundTrip()
+{
+    MCP::Tool tool;
+    tool.strName = "read_temperature";
+    tool.jInputSchema = Json::Value(Json::objectValue);
+    tool.vecTags = { "sensors", "diagnostics" };
+    std::string strTool;
+    Expect(MCP::ERRNO_OK == tool.Serialize(strTool) && strTool.find("\"_meta\":{\"tags\":[\"sensors\",\"diagnostics\"]}") != std::string::npos, "tool tags serialized: " + strTool);
+
+    MCP::Tool parsed;
+    Expect(MCP::ERRNO_OK == parsed.Deserialize(strTool) && parsed.vecTags == tool.vecTags, "tool tags round-trip");
+
+    MCP::Tool untagged;
+    Expect(MCP::ERRNO_OK == untagged.Deserialize(R"({"name":"reboot","inputSchema":{},"category":"actuators"})")
+        && untagged.vecTags == std::vector<std::string>{ "actuators" }, "tool category read as tag");
+}
+
+// Checks a response against the JSON-RPC 2.0 error object rules, with no extra members anywhere
+static bool IsStrictJsonRpcError(const std::string& strMsg)
+{
+    Json::Reader reader;
+    Json::Value jMsg;
+    if (!reader.parse(strMsg, jMsg) || !jMsg.isObject() || jMsg.size() != 3)
+        return false;
+    if (!jMsg.isMember("jsonrpc") || jMsg["jsonrpc"] != "2.0" || !jMsg.isMember("id") || !jMsg.isMember("error"))
+        return false;
+    const auto& jError = jMsg["error"];
+    if (!jError.isObject() || !jError["code"].isIntegral() || !jError["message"].isString())
+        return false;
+    for (const auto& strKey : jError.getMemberNames())
+    {
+        if (strKey != "code" && strKey != "message" && strKey != "data")
+            return false;
+    }
+    return true;
+}
+
+static void TestErrorResponseShape()
+{
+    MCP::ErrorResponse error(false);
+    error.requestId.eIdDataType = MCP::DataType_String;
+    error.requestId.strId = "abc";
+    error.iCode = MCP::ERRNO_INVALID_PARAMS;
+    error.strMesage = "invalid params";
+    std::string strError;
+    Expect(MCP::ERRNO_OK == error.Serialize(strError) && IsStrictJsonRpcError(strError) && strError.find("\"data\"") == std::string::npos, "error without data: " + strError);
+
+    error.jData["argument"] = "n";
+    Expect(MCP::ERRNO_OK == error.Serialize(strError) && IsStrictJsonRpcError(strError) && strError.find("\"data\":{\"argument\":\"n\"}") != std::string::npos, "error with data: " + strError);
+
+    MCP::ErrorResponse parsed(false);
+    Expect(MCP::ERRNO_OK == parsed.Deserialize(strError) && parsed.iCode == error.iCode && parsed.jData == error.jData, "error response round-trip");
+}
+
+static void TestToolTitleRoundTrip()
+{
+    MCP::Tool tool;
+    tool.strName = "set_led";
+    tool.strTitle = "Set LED color";
+    tool.jInputSchema = Json::Value(Json::objectValue);
+    std::string strTool;
+    MCP::Tool parsed;
+    Expect(MCP::ERRNO_OK == tool.Serialize(strTool) && MCP::ERRNO_OK == parsed.Deserialize(strTool)
+        && parsed.strTitle == "Set LED color" && parsed.strName == "set_led", "tool title round-trip: " + strTool);
+
+    tool.strTitle.clear();
+    Expect(MCP::ERRNO_OK == tool.Serialize(strTool) && strTool.find("\"title\":\"set_led\"") != std::string::npos, "tool title falls back to name: " + strTool);
+
+    tool.strSchemaVersion = "3";
+    Expect(MCP::ERRNO_OK == tool.Serialize(strTool) && MCP::ERRNO_OK == parsed.Deserialize(strTool)
+        && strTool.find("\"schemaVersion\":\"3\"") != std::string::npos && parsed.strSchemaVersion == "3", "tool schema version round-trip: " + strTool);
+}
+
+static void TestCallToolMetaRoundTrip()
+{
+    MCP::CallToolRequest request(false);
+    Expect(MCP::ERRNO_OK == request.Deserialize(R"({"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"echo","_meta":{"traceId":"t-1","progressToken":3,"idempotencyKey":"k"}}})"), "call tool request with _meta parsed");
+
+    MCP::CallToolResult result(false);
+    result.requestId = request.requestId;
+    result.jMeta = request.GetEchoedMeta();
+    MCP::TextContent text;
+    text.strType = MCP::CONST_TEXT;
+    text.strText = "ok";
+    result.vecTextContent.push_back(text);
+    std::string strResult;
+    Expect(MCP::ERRNO_OK == result.Serialize(strResult) && strResult.find("\"_meta\":{\"traceId\":\"t-1\"}") != std::string::npos, "result _meta echoes the client's metadata: " + strResult);
+}
+
+static void TestErrorClassification()
+{
+    struct { int iErrCode; int iResponseCode; std::string strMessage; } cases[] = {
+        { MCP::ERRNO_PARSE_ERROR, MCP::ERRNO_PARSE_ERROR, "parse error" },
+        { MCP::ERRNO_METHOD_NOT_FOUND, MCP::ERRNO_METHOD_NOT_FOUND, "method not found" },
+        { MCP::ERRNO_INVALID_PARAMS, MCP::ERRNO_INVALID_PARAMS, "invalid params" },
+        { MCP::ERRNO_SERVER_BUSY, MCP::ERRNO_SERVER_BUSY, "server busy" },
+        { MCP::ERRNO_UNAUTHORIZED, MCP::ERRNO_UNAUTHORIZED, "unauthorized" },
+        { MCP::ERRNO_INTERNAL_INPUT_ERROR, MCP::ERRNO_INTERNAL_ERROR, "internal error" },
+        { MCP::ERRNO_INVALID_NOTIFICATION, MCP::ERRNO_INTERNAL_ERROR, "internal error" },
+        { MCP::ERRNO_OK, MCP::ERRNO_INTERNAL_ERROR, "internal error" },
+    };
+    for (const auto& c : cases)
+    {
+        int iCode = MCP::ErrorHelper::to_response_code(c.iErrCode);
+        Expect(iCode == c.iResponseCode && c.strMessage == MCP::ErrorHelper::get_message(iCode),
+            "error classification for " + std::to_string(c.iErrCode));
+    }
+}
+
+// Replies with the arguments it was called with
+class CArgumentsTask : public MCP::ProcessCallToolRequest
+{
+public:
+    CArgumentsTask(const std::shared_ptr<MCP::Request>& spRequest)
+        : ProcessCallToolRequest(spRequest)
+    {
+
+    }
+
+    std::shared_ptr<CMCPTask> Clone() const override
+    {
+        auto spClone = std::make_shared<CArgumentsTask>(nullptr);
+        if (spClone)
+            *spClone = *this;
+        return spClone;
+    }
+
+    int Execute() override
+    {
+        auto spCallToolRequest = std::dynamic_pointer_cast<MCP::CallToolRequest>(m_spRequest);
+        auto spResult = BuildResult();
+        if (!spCallToolRequest || !spResult)
+            return MCP::ERRNO_INTERNAL_ERROR;
+        MCP::TextContent text;
+        text.strType = MCP::CONST_TEXT;
+        Json::FastWriter writer;
+        writer.omitEndingLineFeed();
+        text.strText = writer.write(spCallToolRequest->jArguments);
+        spResult->vecTextContent.push_back(text);
+        return NotifyResult(spResult);
+    }
+};
+
+// Fails every call with an isError result
+class CToolErrorTask : public MCP::ProcessCallToolRequest
+{
+public:
+    CToolErrorTask(const std::shared_ptr<MCP::Request>& spRequest)
+        : ProcessCallToolRequest(spRequest)
+    {
+
+    }
+
+    std::shared_ptr<CMCPTask> Clone() const override
+    {
+        auto spClone = std::make_shared<CToolErrorTask>(nullptr);
+        if (spClone)
+            *spClone = *this;
+        return spClone;
+    }
+
+    int Execute() override
+    {
+        auto spResult = BuildResult();
+        if (!spResult)
+            return MCP::ERRNO_INTERNAL_ERROR;
+        spResult->bIsError = true;
+        MCP::TextContent text;
+        text.strType = MCP::CONST_TEXT;
+        text.strText = "relay stuck";
+        spResult->vecTextContent.push_back(text);
+        return NotifyResult(spResult);
+    }
+};
+
+// Stays in flight until the test releases it with ReleaseNext
+class CHeldTask : public MCP::ProcessCallToolRequest
+{
+public:
+    CHeldTask(const std::shared_ptr<MCP::Request>& spRequest)
+        : ProcessCallToolRequest(spRequest)
+    {
+
+    }
+
+    std::shared_ptr<CMCPTask> Clone() const override
+    {
+        auto spClone = std::make_shared<CHeldTask>(nullptr);
+        if (spClone)
+        {
+            *spClone = *this;
+            const std::lock_guard<std::mutex> _lock(s_mtxHeld);
+            s_vecCloned.push_back(spClone);
+        }
+        return spClone;
+    }
+
+    int Execute() override
+    {
+        const std::lock_guard<std::mutex> _lock(s_mtxHeld);
+        for (auto& spTask : s_vecCloned)
+        {
+            if (spTask.get() == this)
+                s_deqRunning.push_back(spTask);
+        }
+        return MCP::ERRNO_OK;
+    }
+
+    // Finishes the oldest running task, waiting up to a second for one to start
+    static bool ReleaseNext()
+    {
+        for (int i = 0; i < 200; ++i)
+        {
+            std::shared_ptr<CHeldTask> spTask;
+            {
+                const std::lock_guard<std::mutex> _lock(s_mtxHeld);
+                if (!s_deqRunning.empty())
+                {
+                    spTask = s_deqRunning.front();
+                    s_deqRunning.pop_front();
+                }
+            }
+            if (spTask)
+                return MCP::ERRNO_OK == spTask->NotifyResult(spTask->BuildResult());
+            std::this_thread::sleep_for(std::chrono::milliseconds(5));
+        }
+        return false;
+    }
+
+    static std::size_t GetRunningCount()
+    {
+        const std::lock_guard<std::mutex> _lock(s_mtxHeld);
+        return s_deqRunning.size();
+    }
+
+private:
+    static std::mutex s_mtxHeld;
+    static std::vector<std::shared_ptr<CHeldTask>> s_vecCloned;
+    static std::deque<std::shared_ptr<CHeldTask>> s_deqRunning;
+};
+std::mutex CHeldTask::s_mtxHeld;
+std::vector<std::shared_ptr<CHeldTask>> CHeldTask::s_vecCloned;
+std::deque<std::shared_ptr<CHeldTask>> CHeldTask::s_deqRunning;
+
+// Runs the session on a CMemoryTransport and initializes it as client strClientName
+class CTestSession
+{
+public:
+    CTestSession(const std::string& strClientName = "smoketest")
+        : m_spTransport(std::make_shared<MCP::CMemoryTransport>())
+    {
+        auto& session = MCP::CMCPSession::GetInstance();
+        session.SetTransport(m_spTransport);
+        m_thread = std::thread([&session]()
+        {
+            if (MCP::ERRNO_OK == session.Ready())
+                session.Run();
+        });
+        m_strInitializeResult = Request(R"({"jsonrpc":"2.0","id":"init","method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":")"
+            + strClientName + R"(","version":"1.0"}}})");
+        Push(R"({"jsonrpc":"2.0","method":"notifications/initialized"})");
+    }
+
+    ~CTestSession()
+    {
+        m_spTransport->CloseInput();
+        m_thread.join();
+        MCP::CMCPSession::GetInstance().Terminate();
+    }
+
+    void Push(const std::string& strMsg)
+    {
+        m_spTransport->PushInput(strMsg);
+    }
+
+    // Next message the session writes, empty if none arrives in time
+    std::string Pop(std::chrono::milliseconds timeout = std::chrono::seconds(2))
+    {
+        std::string strOut;
+        if (!m_spTransport->PopOutput(strOut, timeout))
+            strOut.clear();
+        return strOut;
+    }
+
+    std::string Request(const std::string& strRequest)
+    {
+        Push(strRequest);
+        return Pop();
+    }
+
+    std::shared_ptr<MCP::CMemoryTransport> m_spTransport;
+    std::string m_strInitializeResult;
+
+private:
+    std::thread m_thread;
+};
+
+static bool HasId(const std::string& strOut, int iId)
+{
+    return strOut.find("\"id\":" + std::to_string(iId) + ",") != std::string::npos;
+}
+
+static std::string CallTool(int iId, const std::string& strTool, const std::string& strArguments = "{}", const std::string& strMeta = "")
+{
+    return R"({"jsonrpc":"2.0","id":)" + std::to_string(iId) + R"(,"method":"tools/call","params":{"name":")" + strTool + R"(","arguments":)" + strArguments
+        + (strMeta.empty() ? "" : R"(,"_meta":)" + strMeta) + "}}";
+}
+
+static void TestMaxConcurrentCalls()
+{
+    auto& session = MCP::CMCPSession::GetInstance();
+    MCP::Tool held;
+    held.strName = "held";
+    held.jInputSchema = Json::Value(Json::objectValue);
+    session.SetServerTools({ held });
+    session.SetServerCallToolsTasks({ { "held", std::make_shared<CHeldTask>(nullptr) } });
+    {
+        CTestSession client;
+        session.SetServerMaxConcurrentCalls(1);
+        client.Push(CallTool(1, "held"));
+        client.Push(CallTool(2, "held"));
+        std::string strOut = client.Pop();
+        Expect(HasId(strOut, 2) && strOut.find("\"code\":-32006") != std::string::npos, "call over the limit fails fast: " + strOut);
+        Expect(CHeldTask::ReleaseNext() && HasId(strOut = client.Pop(), 1) && strOut.find("\"isError\":false") != std::string::npos, "call within the limit runs: " + strOut);
+
+        session.SetServerMaxConcurrentCalls(1, 2000);
+        client.Push(CallTool(3, "held"));
+        client.Push(CallTool(4, "held"));
+        client.Push(CallTool(5, "held"));
+        Expect(client.Pop(std::chrono::milliseconds(100)).empty() && CHeldTask::GetRunningCount() == 1, "calls over the limit wait");
+        bool bInOrder = true;
+        for (int iId = 3; iId <= 5; ++iId)
+            bInOrder = bInOrder && CHeldTask::ReleaseNext() && HasId(client.Pop(), iId);
+        Expect(bInOrder, "waiting calls run one at a time in order");
+
+        session.SetServerMaxConcurrentCalls(1, 50);
+        client.Push(CallTool(6, "held"));
+        client.Push(CallTool(7, "held"));
+        strOut = client.Pop();
+        Expect(HasId(strOut, 7) && strOut.find("\"code\":-32006") != std::string::npos, "call fails once its wait runs out: " + strOut);
+        Expect(CHeldTask::ReleaseNext() && HasId(client.Pop(), 6), "running call unaffected");
+
+        // A cancelled call keeps its slot until its task actually ends
+        session.SetServerMaxConcurrentCalls(1);
+        client.Push(CallTool(8, "held"));
+        for (int i = 0; i < 200 && CHeldTask::GetRunningCount() == 0; ++i)
+            std::this_thread::sleep_for(std::chrono::milliseconds(5));
+        client.Push(R"({"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":8}})");
+        strOut = client.Request(CallTool(9, "held"));
+        Expect(HasId(strOut, 9) && strOut.find("\"code\":-32006") != std::string::npos, "cancelled call still counts toward the limit: " + strOut);
+        Expect(CHeldTask::ReleaseNext() && client.Pop(std::chrono::milliseconds(100)).empty(), "cancelled call sends no result");
+        client.Push(CallTool(10, "held"));
+        Expect(CHeldTask::ReleaseNext() && HasId(strOut = client.Pop(), 10), "slot free once the cancelled call ended: " + strOut);
+    }
+    session.SetServerMaxConcurrentCalls(0);
+    session.SetServerCallToolsTasks({});
+    session.SetServerTools({});
+}
+
+// Arguments echoed by CArgumentsTask in a tools/call result, null if the call failed
+static Json::Value EchoedArguments(const std::string& strOut)
+{
+    Json::Value jOut;
+    Json::Value jArguments;
+    if (!Json::Reader().parse(strOut, jOut) || !jOut["result"]["content"].isArray() || jOut["result"]["content"].empty()
+        || !Json::Reader().parse(jOut["result"]["content"][0]["text"].asString(), jArguments))
+        return Json::Value();
+    return jArguments;
+}
+
+static void TestArgumentCoercion()
+{
+    auto& session = MCP::CMCPSession::GetInstance();
+    MCP::Tool coerced;
+    coerced.strName = "coerced";
+    Json::Reader().parse(R"({"type":"object","properties":{"n":{"type":"integer"},"f":{"type":"number"},"b":{"type":"boolean"},"s":{"type":"string"}}})", coerced.jInputSchema);
+    session.SetServerTools({ coerced });
+    session.SetServerCallToolsTasks({ { "coerced", std::make_shared<CArgumentsTask>(nullptr) } });
+    {
+        CTestSession client;
+        std::string strOut = client.Request(CallTool(1, "coerced", R"({"n":"42","f":"-1.5e2","b":"true"})"));
+        Expect(strOut.find("\"code\":-32602") != std::string::npos, "strings rejected without coercion: " + strOut);
+
+        session.SetServerArgumentCoercion(true);
+        auto jArguments = EchoedArguments(strOut = client.Request(CallTool(2, "coerced", R"({"n":"42","f":"-1.5e2","b":"true","s":"7"})")));
+        Expect(jArguments["n"].isIntegral() && jArguments["n"].asInt() == 42 && jArguments["f"].isDouble() && jArguments["f"].asDouble() == -150.0
+            && jArguments["b"].isBool() && jArguments["b"].asBool() && jArguments["s"].isString(), "string arguments coerced: " + strOut);
+
+        const char* arrUnconvertible[] = {
+            R"({"n":" 42"})", R"({"n":"42 "})", R"({"n":"4.2"})", R"({"n":"99999999999999999999"})", R"({"f":"nan"})", R"({"f":"inf"})",
+            R"({"f":"0x1p3"})", R"({"f":"1e999"})", R"({"f":"1."})", R"({"f":""})", R"({"b":"yes"})",
+        };
+        int iId = 3;
+        for (auto pszArguments : arrUnconvertible)
+        {
+            strOut = client.Request(CallTool(iId, "coerced", pszArguments));
+            Expect(HasId(strOut, iId++) && strOut.find("\"code\":-32602") != std::string::npos, std::string("unconvertible ") + pszArguments + " rejected: " + strOut);
+        }
+        session.SetServerArgumentCoercion(false);
+    }
+    session.SetServerCallToolsTasks({});
+    session.SetServerTools({});
+}
+
+static void TestIdempotencyKey()
+{
+    auto& session = MCP::CMCPSession::GetInstance();
+    MCP::Tool held;
+    held.strName = "held";
+    held.jInputSchema = Json::Value(Json::objectValue);
+    MCP::Tool other = held;
+    other.strName = "other";
+    session.SetServerTools({ held, other });
+    session.SetServerCallToolsTasks({ { "held", std::make_shared<CHeldTask>(nullptr) }, { "other", std::make_shared<CHeldTask>(nullptr) } });
+    session.SetServerIdempotencyTTL(60);
+    {
+        CTestSession client;
+        const std::string strKey = R"({"idempotencyKey":"dispense-1"})";
+        client.Push(CallTool(1, "held", R"({"n":1})", strKey));
+        std::string strOut = client.Request(CallTool(2, "held", R"({"n":1})", strKey));
+        std::this_thread::sleep_for(std::chrono::milliseconds(50));
+        Expect(HasId(strOut, 2) && strOut.find("\"code\":-32006") != std::string::npos && CHeldTask::GetRunningCount() == 1, "retry while in flight rejected, tool runs once: " + strOut);
+        strOut = client.Request(CallTool(3, "held", R"({"n":2})", strKey));
+        Expect(HasId(strOut, 3) && strOut.find("\"code\":-32602") != std::string::npos, "key reused with other arguments rejected: " + strOut);
+        strOut = client.Request(CallTool(4, "other", R"({"n":1})", strKey));
+        Expect(HasId(strOut, 4) && strOut.find("\"code\":-32602") != std::string::npos, "key reused for another tool rejected: " + strOut);
+
+        Expect(CHeldTask::ReleaseNext() && HasId(strOut = client.Pop(), 1), "first call answered: " + strOut);
+        strOut = client.Request(CallTool(5, "held", R"({"n":1})", strKey));
+        Expect(HasId(strOut, 5) && strOut.find("\"isError\":false") != std::string::npos && CHeldTask::GetRunningCount() == 0, "repeated key replays the result: " + strOut);
+        client.Push(CallTool(6, "held", R"({"n":1})", R"({"idempotencyKey":"dispense-2"})"));
+        Expect(client.Pop(std::chrono::milliseconds(100)).empty() && CHeldTask::GetRunningCount() == 1 && CHeldTask::ReleaseNext() && HasId(client.Pop(), 6), "new key runs the tool again");
+    }
+    session.SetServerIdempotencyTTL(0);
+    session.SetServerCallToolsTasks({});
+    session.SetServerTools({});
+}
+
+static void TestToolNotFound()
+{
+    auto& session = MCP::CMCPSession::GetInstance();
+    MCP::Tool taskless;
+    taskless.strName = "taskless";
+    taskless.jInputSchema = Json::Value(Json::objectValue);
+    session.SetServerTools({ taskless });
+    {
+        CTestSession client;
+        Json::Value jOut;
+        std::string strOut = client.Request(CallTool(1, "missing"));
+        Expect(Json::Reader().parse(strOut, jOut) && jOut["error"]["code"].asInt() == MCP::ERRNO_INVALID_PARAMS
+            && jOut["error"]["message"].asString() == "unknown tool: missing", "unknown tool is a bad argument: " + strOut);
+
+        strOut = client.Request(CallTool(2, "taskless"));
+        Expect(Json::Reader().parse(strOut, jOut) && jOut["error"]["code"].asInt() == MCP::ERRNO_INTERNAL_ERROR
+            && jOut["error"]["message"].asString() == "internal error", "tool without a task is an internal error: " + strOut);
+        session.SetServerErrorVerbosity(MCP::CMCPSession::ErrorVerbosity_Detailed);
+        strOut = client.Request(CallTool(3, "taskless"));
+        Expect(Json::Reader().parse(strOut, jOut) && jOut["error"]["code"].asInt() == MCP::ERRNO_INTERNAL_ERROR
+            && jOut["error"]["message"].asString() == "no task registered for tool: taskless", "missing task named with detailed errors: " + strOut);
+        session.SetServerErrorVerbosity(MCP::CMCPSession::ErrorVerbosity_Minimal);
+        auto vecErrors = client.m_spTransport->GetErrors();
+        Expect(!vecErrors.empty() && vecErrors[0] == "error -32602: unknown tool: missing"
+            && vecErrors.size() >= 2 && vecErrors[1] == "error -32603: no task registered for tool: taskless", "both not-found paths logged");
+
+        strOut = client.Request(R"({"jsonrpc":"2.0","id":4,"method":"tools/unknown"})");
+        Expect(Json::Reader().parse(strOut, jOut) && jOut["error"]["code"].asInt() == MCP::ERRNO_METHOD_NOT_FOUND, "unknown method: " + strOut);
+    }
+    session.SetServerTools({});
+}
+
+// Instructions sent in the initialize result a CTestSession received
+static std::string InitializeInstructions(const CTestSession& client)
+{
+    Json::Value jOut;
+    if (!Json::Reader().parse(client.m_strInitializeResult, jOut))
+        return "<unparsed>";
+    return jOut["result"]["instructions"].asString();
+}
+
+static void TestClientInstructions()
+{
+    auto& session = MCP::CMCPSession::GetInstance();
+    session.SetServerInstructions("default instructions");
+    session.SetServerClientInstructions("smoketest-voice", "short answers");
+    {
+        CTestSession client("smoketest-voice");
+        Expect(InitializeInstructions(client) == "short answers", "matching client gets its instructions: " + client.m_strInitializeResult);
+    }
+    {
+        CTestSession client("smoketest-other");
+        Expect(InitializeInstructions(client) == "default instructions", "other client gets the default: " + client.m_strInitializeResult);
+    }
+    session.SetServerInstructions("");
+}
+
+static void SetEnvironment(const char* pszName, const char* pszValue)
+{
+#ifdef _WIN32
+    _putenv_s(pszName, pszValue ? pszValue : "");
+#else
+    if (pszValue)
+        setenv(pszName, pszValue, 1);
+    else
+        unsetenv(pszName);
+#endif
+}
+
+static void TestInstructionsFile()
+{
+    const std::string strPath = "tinymcp_smoketest_instructions.txt";
+    {
+        std::ofstream file(strPath, std::ios::binary);
+        file << "from the file\r\n";
+    }
+    auto& session = MCP::CMCPSession::GetInstance();
+    session.SetServerInstructions("default instructions");
+
+    session.SetServerInstructionsFile(strPath);
+    {
+        CTestSession client;
+        Expect(InitializeInstructions(client) == "from the file", "instructions read from the file: " + client.m_strInitializeResult);
+    }
+    session.SetServerInstructionsFile("");
+    SetEnvironment("TINYMCP_INSTRUCTIONS_FILE", strPath.c_str());
+    {
+        CTestSession client;
+        Expect(InitializeInstructions(client) == "from the file", "instructions file taken from the environment: " + client.m_strInitializeResult);
+    }
+    SetEnvironment("TINYMCP_INSTRUCTIONS_FILE", nullptr);
+
+    std::remove(strPath.c_str());
+    session.SetServerInstructionsFile(strPath);
+    {
+        CTestSession client;
+        auto vecErrors = client.m_spTransport->GetErrors();
+        Expect(InitializeInstructions(client) == "default instructions" && !vecErrors.empty()
+            && vecErrors[0] == "cannot read instructions file " + strPath + ", using the default instructions", "unreadable file falls back to the default: " + client.m_strInitializeResult);
+    }
+    session.SetServerInstructionsFile("");
+    session.SetServerInstructions("");
+}
+
+static void TestTerminateResetsSession()
+{
+    auto& session = MCP::CMCPSession::GetInstance();
+    const auto capabilities = session.GetServerCapabilities();
+    auto logging = capabilities;
+    logging.logging.bExist = true;
+    session.SetServerCapabilities(logging);
+    MCP::Tool echo;
+    echo.strName = "echo";
+    echo.jInputSchema = Json::Value(Json::objectValue);
+    session.SetServerTools({ echo });
+    session.SetServerCallToolsTasks({ { "echo", std::make_shared<CArgumentsTask>(nullptr) } });
+    session.SetServerIdempotencyTTL(60);
+    session.SetServerInitializeTimeout(5000);
+    const std::string strKey = R"({"idempotencyKey":"reset-1"})";
+    {
+        CTestSession client;
+        std::string strOut = client.Request(R"({"jsonrpc":"2.0","id":1,"method":"logging/setLevel","params":{"level":"error"}})");
+        Expect(HasId(strOut, 1) && strOut.find("\"error\"") == std::string::npos, "first client sets log level error: " + strOut);
+        session.Log("info", "dropped");
+        Expect(client.Pop(std::chrono::milliseconds(100)).empty(), "info below the first client's level");
+        strOut = client.Request(CallTool(2, "echo", R"({"n":1})", strKey));
+        Expect(EchoedArguments(strOut)["n"].asInt() == 1, "first client's keyed call answered: " + strOut);
+        Expect(session.IsAsyncTaskThreadRunning() && session.IsInitializeWatchdogRunning(), "threads started for the first client");
+    }
+    Expect(!session.IsAsyncTaskThreadRunning() && !session.IsInitializeWatchdogRunning(), "Terminate joins the async and watchdog threads");
+    {
+        CTestSession client;
+        std::string strOut = client.Request(CallTool(3, "echo", R"({"n":2})", strKey));
+        Expect(EchoedArguments(strOut)["n"].asInt() == 2, "previous client's idempotency key forgotten: " + strOut);
+        session.Log("info", "delivered");
+        strOut = client.Pop();
+        Expect(strOut.find("notifications/message") != std::string::npos && strOut.find("delivered") != std::string::npos, "log level back to info for the next client: " + strOut);
+    }
+    session.SetServerInitializeTimeout(0);
+    session.SetServerIdempotencyTTL(0);
+    session.SetServerCallToolsTasks({});
+    session.SetServerTools({});
+    session.SetServerCapabilities(capabilities);
+}
+
+static void TestArgumentDefaults()
+{
+    auto& session = MCP::CMCPSession::GetInstance();
+    MCP::Tool defaults;
+    defaults.strName = "defaults";
+    Json::Reader().parse(R"({"type":"object","properties":{"n":{"type":"integer","default":5},"s":{"type":"string","default":"dflt"},"f":{"type":"number"}},"required":["f","n"]})", defaults.jInputSchema);
+    session.SetServerTools({ defaults });
+    session.SetServerCallToolsTasks({ { "defaults", std::make_shared<CArgumentsTask>(nullptr) } });
+    {
+        CTestSession client;
+        std::string strOut = client.Request(CallTool(1, "defaults", R"({"f":1.5})"));
+        auto jArguments = EchoedArguments(strOut);
+        Expect(jArguments["n"].asInt() == 5 && jArguments["s"].asString() == "dflt" && jArguments["f"].asDouble() == 1.5, "omitted arguments filled from defaults: " + strOut);
+        strOut = client.Request(CallTool(2, "defaults", R"({"f":1.5,"n":7,"s":"x"})"));
+        jArguments = EchoedArguments(strOut);
+        Expect(jArguments["n"].asInt() == 7 && jArguments["s"].asString() == "x", "client values override defaults: " + strOut);
+
+        Json::Value jOut;
+        strOut = client.Request(CallTool(3, "defaults", R"({"n":1})"));
+        Expect(Json::Reader().parse(strOut, jOut) && jOut["error"]["code"].asInt() == MCP::ERRNO_INVALID_PARAMS
+            && jOut["error"]["message"].asString() == "invalid params: missing required argument 'f'", "required argument without a default enforced: " + strOut);
+        strOut = client.Request(CallTool(4, "defaults", R"("f")"));
+        Expect(Json::Reader().parse(strOut, jOut) && jOut["error"]["code"].asInt() == MCP::ERRNO_INVALID_PARAMS
+            && jOut["error"]["message"].asString() == "invalid params: arguments must be an object", "non-object arguments rejected: " + strOut);
+    }
+    session.SetServerCallToolsTasks({});
+    session.SetServerTools({});
+}
+
+static void TestArgumentLimits()
+{
+    auto& session = MCP::CMCPSession::GetInstance();
+    MCP::Tool echo;
+    echo.strName = "echo";
+    session.SetServerTools({ echo });
+    session.SetServerCallToolsTasks({ { "echo", std::make_shared<CArgumentsTask>(nullptr) } });
+    session.SetServerArgumentLimits(64, 3);
+    {
+        CTestSession client;
+        std::string strOut = client.Request(CallTool(1, "echo", R"({"a":{"b":{}},"s":"short"})"));
+        Expect(EchoedArguments(strOut)["s"].asString() == "short", "arguments within both limits accepted: " + strOut);
+
+        Json::Value jOut;
+        strOut = client.Request(CallTool(2, "echo", "{\"s\":\"" + std::string(100, 'x') + "\"}"));
+        Expect(Json::Reader().parse(strOut, jOut) && jOut["error"]["code"].asInt() == MCP::ERRNO_INVALID_PARAMS
+            && jOut["error"]["message"].asString() == "invalid params: arguments are 108 bytes, the limit is 64", "oversized arguments rejected: " + strOut);
+        strOut = client.Request(CallTool(3, "echo", R"({"a":{"b":{"c":{}}}})"));
+        Expect(Json::Reader().parse(strOut, jOut) && jOut["error"]["code"].asInt() == MCP::ERRNO_INVALID_PARAMS
+            && jOut["error"]["message"].asString() == "invalid params: arguments nested deeper than 3 levels", "deeply nested arguments rejected: " + strOut);
+    }
+    session.SetServerArgumentLimits(0, 0);
+    session.SetServerCallToolsTasks({});
+    session.SetServerTools({});
+}
+
+static void TestJsonRpcVersion()
+{
+    CTestSession client;
+    std::string strOut = client.Request(R"({"jsonrpc":"1.0","id":41,"method":"ping"})");
+    Expect(HasId(strOut, 41) && strOut.find("\"code\":-32600") != std::string::npos && IsStrictJsonRpcError(strOut), "jsonrpc 1.0 rejected: " + strOut);
+    strOut = client.Request(R"({"id":"v","method":"ping"})");
+    Expect(strOut.find("\"id\":\"v\"") != std::string::npos && strOut.find("\"code\":-32600") != std::string::npos, "missing jsonrpc rejected: " + strOut);
+    strOut = client.Request(R"({"jsonrpc":"2.0","id":42,"method":"ping"})");
+    Expect(strOut == "{\"id\":42,\"jsonrpc\":\"2.0\",\"result\":{}}\n", "session keeps serving after a bad version: " + strOut);
+}
+
+static void TestSessionOverMemoryTransport()
+{
+    auto spTransport = std::make_shared<MCP::CMemoryTransport>();
+    auto& session = MCP::CMCPSession::GetInstance();
+    session.SetTransport(spTransport);
+    MCP::Implementation serverInfo;
+    serverInfo.strName = "smoketest";
+    serverInfo.strVersion = "1.0";
+    session.SetServerInfo(serverInfo);
+
+    std::thread sessionThread([&session]()
+    {
+        if (MCP::ERRNO_OK == session.Ready())
+            session.Run();
+    });
+
+    const auto timeout = std::chrono::seconds(2);
+    std::string strOut;
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","clientInfo":{"name":"smoketest","version":"1.0"}}})");
+    Expect(spTransport->PopOutput(strOut, timeout) && strOut.find("\"serverInfo\":{\"name\":\"smoketest\"") != std::string::npos, "initialize result: " + strOut);
+
+    spTransport->PushInput(R"({"jsonrpc":"2.0","method":"notifications/initialized"})");
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":2,"method":"ping"})");
+    Expect(spTransport->PopOutput(strOut, timeout) && strOut == "{\"id\":2,\"jsonrpc\":\"2.0\",\"result\":{}}\n", "ping over memory transport: " + strOut);
+
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":3,"method":"no/such/method"})");
+    Expect(spTransport->PopOutput(strOut, timeout) && IsStrictJsonRpcError(strOut), "unknown method error shape: " + strOut);
+
+    const std::string strPingWithExtra = R"({"jsonrpc":"2.0","id":4,"method":"ping","params":{"verbose":true}})";
+    spTransport->PushInput(strPingWithExtra);
+    Expect(spTransport->PopOutput(strOut, timeout) && strOut.find("\"result\":{}") != std::string::npos, "lenient parsing ignores extra fields: " + strOut);
+    session.SetServerStrictParsing(true);
+    spTransport->PushInput(strPingWithExtra);
+    Expect(spTransport->PopOutput(strOut, timeout) && strOut.find("\"code\":-32602") != std::string::npos && strOut.find("verbose") != std::string::npos, "strict parsing rejects extra fields: " + strOut);
+    session.SetServerStrictParsing(false);
+
+    MCP::Tool versioned;
+    versioned.strName = "versioned";
+    versioned.jInputSchema = Json::Value(Json::objectValue);
+    versioned.strSchemaVersion = "2";
+    MCP::ServerCapabilities capabilities;
+    capabilities.tools.bExist = true;
+    session.SetServerCapabilities(capabilities);
+    session.SetServerTools({ versioned });
+    session.SetServerSchemaVersionCheck(true);
+    // No task is registered for the tool, getting past the version check ends in that error instead
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"versioned","arguments":{},"_meta":{"schemaVersion":"1"}}})");
+    Expect(spTransport->PopOutput(strOut, timeout) && strOut.find("schema changed") != std::string::npos, "stale schema version rejected: " + strOut);
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"versioned","arguments":{},"_meta":{"schemaVersion":"2"}}})");
+    Expect(spTransport->PopOutput(strOut, timeout) && strOut.find("schema changed") == std::string::npos && strOut.find("\"code\":-32603") != std::string::npos, "current schema version accepted: " + strOut);
+    session.SetServerSchemaVersionCheck(false);
+
+    session.SetServerDisabledMethods({ "tools/list" });
+    Expect(!session.GetServerCapabilities().tools.bExist, "disabled listing method hides the capability");
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":7,"method":"tools/list"})");
+    Expect(spTransport->PopOutput(strOut, timeout) && strOut.find("\"code\":-32601") != std::string::npos, "disabled method rejected: " + strOut);
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":8,"method":"ping"})");
+    Expect(spTransport->PopOutput(strOut, timeout) && strOut.find("\"result\":{}") != std::string::npos, "ping still served: " + strOut);
+    session.SetServerDisabledMethods({});
+    Expect(session.GetServerCapabilities().tools.bExist, "capability back once the method is enabled");
+
+    MCP::Tool typed;
+    typed.strName = "typed";
+    Json::Reader().parse(R"({"type":"object","properties":{"count":{"type":"integer"},"label":{"type":["string","null"]}}})", typed.jInputSchema);
+    session.SetServerTools({ typed });
+    session.SetServerCallToolsTasks({ { "typed", std::make_shared<CArgumentsTask>(nullptr) } });
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":9,"method":"tools/call","params":{"name":"typed","arguments":{"count":{"n":1}}}})");
+    Expect(spTransport->PopOutput(strOut, timeout) && strOut.find("\"code\":-32602") != std::string::npos
+        && strOut.find("argument 'count' must be integer") != std::string::npos, "mistyped argument named in the error: " + strOut);
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":10,"method":"tools/call","params":{"name":"typed","arguments":{"count":3,"label":null}}})");
+    Expect(spTransport->PopOutput(strOut, timeout) && strOut.find("\"isError\":false") != std::string::npos
+        && strOut.find("\"timing\"") == std::string::npos, "well-typed arguments reach the task: " + strOut);
+    session.SetServerTimingMeta(true);
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":13,"method":"tools/call","params":{"name":"typed","arguments":{}}})");
+    Json::Value jTimed;
+    Expect(spTransport->PopOutput(strOut, timeout) && Json::Reader().parse(strOut, jTimed) && jTimed["result"]["_meta"]["timing"].isObject()
+        && jTimed["result"]["_meta"]["timing"]["totalMs"].isIntegral() && jTimed["result"]["_meta"]["timing"].isMember("validationMs")
+        && jTimed["result"]["_meta"]["timing"].isMember("queueMs") && jTimed["result"]["_meta"]["timing"].isMember("executeMs"), "timing breakdown when enabled: " + strOut);
+    session.SetServerTimingMeta(false);
+
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":11,"method":"tools/call","params":{"name":"unlisted","arguments":{"x":1}}})");
+    Expect(spTransport->PopOutput(strOut, timeout) && strOut.find("unknown tool: unlisted") != std::string::npos, "unknown tool rejected by default: " + strOut);
+    session.SetServerFallbackCallToolTask(std::make_shared<CArgumentsTask>(nullptr));
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":12,"method":"tools/call","params":{"name":"unlisted","arguments":{"x":1}}})");
+    Expect(spTransport->PopOutput(strOut, timeout) && strOut.find("{\\\"x\\\":1}") != std::string::npos, "unknown tool handed to the fallback task: " + strOut);
+    session.SetServerFallbackCallToolTask(nullptr);
+    session.SetServerCallToolsTasks({});
+    session.SetServerTools({});
+    session.SetServerCapabilities(MCP::ServerCapabilities());
+
+    Json::Value jSampled;
+    Expect(MCP::ERRNO_METHOD_NOT_FOUND == session.RequestSampling(Json::Value(Json::objectValue), jSampled, 100), "sampling needs the client capability");
+
+    spTransport->CloseInput();
+    sessionThread.join();
+    session.Terminate();
+}
+
+static void TestErrorVerbosity()
+{
+    auto spTransport = std::make_shared<MCP::CMemoryTransport>();
+    auto& session = MCP::CMCPSession::GetInstance();
+    session.SetTransport(spTransport);
+    auto spRequest = std::make_shared<MCP::Request>(MCP::MessageType_Unknown, false);
+    spRequest->requestId.eIdDataType = MCP::DataType_Integer;
+    spRequest->requestId.iId = 4;
+    Json::Value jData(Json::objectValue);
+    jData["upstream"] = "connection refused";
+
+    const auto timeout = std::chrono::seconds(1);
+    std::string strOut;
+    Json::Value jError;
+    auto sendError = [&](int iCode, const std::string& strMessage)
+    {
+        MCP::ProcessErrorRequest task(spRequest);
+        task.SetErrorCode(iCode);
+        task.SetErrorMessage(strMessage);
+        task.SetErrorData(jData);
+        jError = Json::Value();
+        return MCP::ERRNO_OK == task.Execute() && spTransport->PopOutput(strOut, timeout)
+            && Json::Reader().parse(strOut, jError) && jError["error"]["code"].asInt() == iCode;
+    };
+    const std::string strInvalid = "invalid params: argument 'n' must be integer";
+    const std::string strInternal = "internal error: device rpc timed out";
+
+    Expect(sendError(MCP::ERRNO_INVALID_PARAMS, strInvalid) && jError["error"]["message"].asString() == strInvalid
+        && !jError["error"].isMember("data"), "minimal error keeps what the client can fix: " + strOut);
+    Expect(sendError(MCP::ERRNO_INTERNAL_ERROR, strInternal) && jError["error"]["message"].asString() == "internal error"
+        && !jError["error"].isMember("data"), "minimal error hides internal details: " + strOut);
+    Expect(sendError(MCP::ERRNO_SERVER_BUSY, "server busy: upstream unavailable") && jError["error"]["message"].asString() == "server busy"
+        && !jError["error"].isMember("data"), "minimal error hides classified failures: " + strOut);
+    Expect(sendError(-32050, "upstream timed out after 5000 ms") && jError["error"]["message"].asString() == "internal error",
+        "minimal error hides server codes without a default message: " + strOut);
+    Expect(sendError(MCP::ERRNO_METHOD_NOT_FOUND, "method not found: tools/run") && jError["error"]["message"].asString() == "method not found: tools/run",
+        "minimal error keeps client faults: " + strOut);
+    session.SetServerErrorVerbosity(MCP::CMCPSession::ErrorVerbosity_Detailed);
+    Expect(sendError(MCP::ERRNO_INTERNAL_ERROR, strInternal) && jError["error"]["message"].asString() == strInternal
+        && jError["error"]["data"] == jData, "detailed error: " + strOut);
+    session.SetServerErrorVerbosity(MCP::CMCPSession::ErrorVerbosity_Minimal);
+
+    auto vecErrors = spTransport->GetErrors();
+    Expect(vecErrors.size() == 6 && vecErrors[1] == "error -32603: " + strInternal + " data: {\"upstream\":\"connection refused\"}",
+        "details and data logged whatever the verbosity");
+}
+
+static void TestCapabilitiesOverride()
+{
+    auto& session = MCP::CMCPSession::GetInstance();
+    Json::Value jOverride;
+    Json::Reader().parse(R"({"tools":{"listChanged":true},"experimental":{"batching":{}}})", jOverride);
+
+    session.SetServerDisabledMethods({ "tools/list" });
+    Expect(MCP::ERRNO_INVALID_PARAMS == session.SetServerCapabilitiesOverride(jOverride), "override advertising a disabled method rejected");
+    session.SetServerDisabledMethods({});
+    Expect(MCP::ERRNO_OK == session.SetServerCapabilitiesOverride(jOverride), "override accepted");
+
+    MCP::InitializeResult result(false);
+    result.requestId.eIdDataType = MCP::DataType_Integer;
+    result.requestId.iId = 1;
+    result.strProtocolVersion = MCP::PROTOCOL_VER;
+    result.implServerInfo.strName = "smoketest";
+    result.implServerInfo.strVersion = "1.0";
+    result.jCapabilitiesOverride = session.GetServerCapabilitiesOverride();
+    std::string strResult;
+    Json::Value jResult;
+    Expect(MCP::ERRNO_OK == result.Serialize(strResult) && Json::Reader().parse(strResult, jResult)
+        && jResult["result"]["capabilities"] == jOverride, "override sent as the initialize capabilities: " + strResult);
+
+    // Disabling the method later withdraws the capability from the override
+    session.SetServerDisabledMethods({ "tools/list" });
+    auto jEffective = session.GetServerCapabilitiesOverride();
+    Expect(!jEffective.isMember("tools") && jEffective.isMember("experimental"), "later disabled method left out of the override");
+    session.SetServerDisabledMethods({});
+    session.SetServerCapabilitiesOverride(Json::Value());
+}
+
+static void TestSamplingOverMemoryTransport()
+{
+    auto spTransport = std::make_shared<MCP::CMemoryTransport>();
+    auto& session = MCP::CMCPSession::GetInstance();
+    session.SetTransport(spTransport);
+
+    std::thread sessionThread([&session]()
+    {
+        if (MCP::ERRNO_OK == session.Ready())
+            session.Run();
+    });
+
+    const auto timeout = std::chrono::seconds(2);
+    std::string strOut;
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{"sampling":{}},"clientInfo":{"name":"smoketest","version":"1.0"}}})");
+    Expect(spTransport->PopOutput(strOut, timeout), "initialize with sampling: " + strOut);
+    spTransport->PushInput(R"({"jsonrpc":"2.0","method":"notifications/initialized"})");
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":2,"method":"ping"})");
+    Expect(spTransport->PopOutput(strOut, timeout), "ping before sampling: " + strOut);
+
+    Json::Value jParams(Json::objectValue);
+    jParams["maxTokens"] = 16;
+    Json::Value jResult;
+    int iSampling = MCP::ERRNO_INTERNAL_ERROR;
+    std::thread samplingThread([&]() { iSampling = session.RequestSampling(jParams, jResult, 2000); });
+
+    Json::Value jRequest;
+    Expect(spTransport->PopOutput(strOut, timeout) && Json::Reader().parse(strOut, jRequest)
+        && jRequest["method"].asString() == "sampling/createMessage" && jRequest["params"]["maxTokens"].asInt() == 16, "sampling request sent: " + strOut);
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":")" + jRequest["id"].asString() + R"(","result":{"role":"assistant","content":{"type":"text","text":"hi"},"model":"m"}})");
+    samplingThread.join();
+    Expect(MCP::ERRNO_OK == iSampling && jResult["content"]["text"].asString() == "hi", "sampling result delivered");
+
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":"tinymcp-unknown","error":{"code":-1,"message":"late"}})");
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":3,"method":"ping"})");
+    Expect(spTransport->PopOutput(strOut, timeout) && strOut.find("\"id\":3") != std::string::npos, "unmatched response is ignored: " + strOut);
+
+    spTransport->CloseInput();
+    sessionThread.join();
+    session.Terminate();
+}
+
+static void TestInitializeTimeout()
+{
+    auto spTransport = std::make_shared<MCP::CMemoryTransport>();
+    auto& session = MCP::CMCPSession::GetInstance();
+    session.SetTransport(spTransport);
+    session.SetServerInitializeTimeout(100);
+
+    // A client that connects and stays silent is disconnected, which ends Run
+    std::thread silentThread([&session]()
+    {
+        if (MCP::ERRNO_OK == session.Ready())
+            session.Run();
+    });
+    silentThread.join();
+    Expect(MCP::CMCPSession::SessionState_Original == session.GetSessionState(), "silent client never initialized");
+    session.Terminate();
+
+    // One that initializes in time keeps its connection past the window
+    std::thread sessionThread([&session]()
+    {
+        if (MCP::ERRNO_OK == session.Ready())
+            session.Run();
+    });
+    const auto timeout = std::chrono::seconds(2);
+    std::string strOut;
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"smoketest","version":"1.0"}}})");
+    Expect(spTransport->PopOutput(strOut, timeout), "initialize within the window: " + strOut);
+    spTransport->PushInput(R"({"jsonrpc":"2.0","method":"notifications/initialized"})");
+    std::this_thread::sleep_for(std::chrono::milliseconds(200));
+    spTransport->PushInput(R"({"jsonrpc":"2.0","id":2,"method":"ping"})");
+    Expect(spTransport->PopOutput(strOut, timeout) && strOut.find("\"id\":2") != std::string::npos, "initialized client not disconnected: " + strOut);
+
+    spTransport->CloseInput();
+    sessionThread.join();
+    session.Terminate();
+    session.SetServerInitializeTimeout(0);
+}
+
+// Stands in for the stdin of a client that stays connected: reads block until input is pushed or it is closed
+class CBlockingStreambuf : public std::streambuf
+{
+public:
+    void Push(const std::string& strInput)
+    {
+        {
+            const std::lock_guard<std::mutex> _lock(m_mtx);
+            m_strPending += strInput;
+        }
+        m_cv.notify_all();
+    }
+
+    void Close()
+    {
+        {
+            const std::lock_guard<std::mutex> _lock(m_mtx);
+            m_bClosed = true;
+        }
+        m_cv.notify_all();
+    }
+
+protected:
+    int_type underflow() override
+    {
+        std::unique_lock<std::mutex> _lock(m_mtx);
+        m_cv.wait(_lock, [this]() { return m_bClosed || !m_strPending.empty(); });
+        if (m_strPending.empty())
+            return traits_type::eof();
+        m_strBuffer.swap(m_strPending);
+        m_strPending.clear();
+        setg(&m_strBuffer[0], &m_strBuffer[0], &m_strBuffer[0] + m_strBuffer.size());
+        return traits_type::to_int_type(m_strBuffer[0]);
+    }
+
+private:
+    std::mutex m_mtx;
+    std::condition_variable m_cv;
+    std::string m_strPending;
+    std::string m_strBuffer;
+    bool m_bClosed{ false };
+};
+
+static void TestStdioInitializeTimeout()
+{
+    CBlockingStreambuf stdinBuffer;
+    auto pStdin = std::cin.rdbuf(&stdinBuffer);
+    auto spTransport = std::make_shared<MCP::CStdioTransport>();
+    std::string strLine;
+    spTransport->Connect();
+    stdinBuffer.Push("{\"jsonrpc\":\"2.0\"}\n");
+    Expect(MCP::ERRNO_OK == spTransport->Read(strLine) && strLine == R"({"jsonrpc":"2.0"})", "stdio line read: " + strLine);
+
+    // A silent client keeps stdin open, the initialize timeout still ends the blocked read and Run
+    auto& session = MCP::CMCPSession::GetInstance();
+    session.SetTransport(spTransport);
+    session.SetServerInitializeTimeout(100);
+    std::atomic_bool bRunEnded{ false };
+    std::thread silentThread([&session, &bRunEnded]()
+    {
+        if (MCP::ERRNO_OK == session.Ready())
+            session.Run();
+        bRunEnded = true;
+    });
+    for (int i = 0; i < 200 && !bRunEnded; ++i)
+        std::this_thread::sleep_for(std::chrono::milliseconds(10));
+    Expect(bRunEnded, "silent stdio client disconnected");
+    stdinBuffer.Close();
+    silentThread.join();
+    session.Terminate();
+    session.SetServerInitializeTimeout(0);
+
+    // Waits for the reading thread to see the end of stdin before the buffer goes away
+    spTransport->Connect();
+    Expect(MCP::ERRNO_INTERNAL_INPUT_TERMINATE == spTransport->Read(strLine), "end of stdin reported");
+    std::cin.rdbuf(pStdin);
+    std::cin.clear();
+}
+
+static void TestStartupSummary()
+{
+    const std::string strPath = "tinymcp_smoketest_config.ini";
+    {
+        std::ofstream file(strPath);
+        file << "[server]\nhost=127.0.0.1\nport=7777\n[auth]\nenable_auth=1\napi_key=s3cret-key\n";
+    }
+    auto& config = MCP::Config::GetInstance();
+    Expect(MCP::ERRNO_OK == config.LoadFromFile(strPath), "summary config loaded");
+
+    auto spTransport = std::make_shared<MCP::CMemoryTransport>();
+    MCP::CMCPSession::GetInstance().SetTransport(spTransport);
+    auto& server = CSmoketestServer::Get();
+    server.Initialize();
+    server.LogStartupSummary();
+
+    auto vecErrors = spTransport->GetErrors();
+    Json::Value jSummary;
+    Expect(vecErrors.size() == 1 && Json::Reader().parse(vecErrors[0], jSummary), "one summary line logged");
+    Expect(jSummary["event"].asString() == "startup" && jSummary["server"].asString() == "smoketest" && jSummary["version"].asString() == "1.0"
+        && jSummary["protocolVersion"].asString() == MCP::PROTOCOL_VER && jSummary["transport"].asString() == "custom"
+        && jSummary["host"].asString() == "127.0.0.1" && jSummary["port"].asInt() == 7777 && !jSummary["https"].asBool() && jSummary["auth"].asBool()
+        && jSummary["capabilities"].size() == 1 && jSummary["capabilities"][0].asString() == "tools", "summary fields: " + (vecErrors.empty() ? "" : vecErrors[0]));
+    Expect(vecErrors.size() == 1 && vecErrors[0].find("s3cret-key") == std::string::npos, "api key never logged");
+
+    MCP::Logging logging;
+    server.RegisterServerLoggingCapabilities(logging);
+    server.LogStartupSummary();
+    vecErrors = spTransport->GetErrors();
+    Expect(vecErrors.size() == 2 && Json::Reader().parse(vecErrors[1], jSummary) && jSummary["capabilities"].size() == 2
+        && jSummary["capabilities"][1].asString() == "logging", "logging listed once registered: " + (vecErrors.size() < 2 ? "" : vecErrors[1]));
+    logging.bExist = false;
+    server.RegisterServerLoggingCapabilities(logging);
+
+    // Later tests expect auth to be off again
+    {
+        std::ofstream file(strPath);
+        file << "[server]\nhost=localhost\nport=6666\n[auth]\nenable_auth=0\napi_key=\n";
+    }
+    config.LoadFromFile(strPath);
+    std::remove(strPath.c_str());
+}
+
+static void TestEmptyCallToolResult()
+{
+    MCP::CallToolResult result(false);
+    result.requestId.eIdDataType = MCP::DataType_Integer;
+    result.requestId.iId = 8;
+    result.jMeta = Json::Value(Json::objectValue);
+    std::string strResult;
+    Expect(MCP::ERRNO_OK == result.Serialize(strResult) && strResult == "{\"id\":8,\"jsonrpc\":\"2.0\",\"result\":{\"content\":[],\"isError\":false}}\n", "empty tool result: " + strResult);
+}
+
+static void TestResourceLinkContent()
+{
+    MCP::CallToolResult result(false);
+    result.requestId.eIdDataType = MCP::DataType_Integer;
+    result.requestId.iId = 9;
+    MCP::ResourceLink link;
+    link.strType = MCP::CONST_RESOURCE_LINK;
+    link.strUri = "https://example.com/logs/42.txt";
+    link.strName = "42.txt";
+    link.strMimeType = "text/plain";
+    result.vecResourceLinks.push_back(link);
+    std::string strResult;
+    Json::Value jResult;
+    Expect(MCP::ERRNO_OK == result.Serialize(strResult) && Json::Reader().parse(strResult, jResult)
+        && jResult["result"]["content"].size() == 1 && jResult["result"]["content"][0]["type"] == "resource_link"
+        && jResult["result"]["content"][0]["uri"] == "https://example.com/logs/42.txt" && !jResult["result"]["content"][0].isMember("description"), "resource link content: " + strResult);
+
+    MCP::ResourceLink parsed;
+    Expect(MCP::ERRNO_OK == parsed.DoDeserialize(jResult["result"]["content"][0]) && parsed.IsValid() && parsed.strMimeType == "text/plain", "resource link round-trip");
+}
+
+static void TestToolResultLimit()
+{
+    auto& session = MCP::CMCPSession::GetInstance();
+    auto fnResult = []()
+    {
+        MCP::CallToolResult result(false);
+        MCP::TextContent text;
+        text.strType = MCP::CONST_TEXT;
+        text.strText = u8"\u00e9\u00e9\u00e9";
+        result.vecTextContent = { text, text };
+        return result;
+    };
+
+    session.SetServerMaxResultBytes(6, true);
+    auto underLimit = fnResult();
+    underLimit.vecTextContent.pop_back();
+    session.LimitToolResult(underLimit);
+    Expect(underLimit.vecTextContent[0].strText == u8"\u00e9\u00e9\u00e9" && !underLimit.jMeta.isMember("truncatedBytes"), "result under the limit is untouched");
+
+    session.SetServerMaxResultBytes(3, true);
+    auto truncated = fnResult();
+    session.LimitToolResult(truncated);
+    Expect(truncated.vecTextContent.size() == 1 && truncated.vecTextContent[0].strText == u8"\u00e9\n[truncated]"
+        && truncated.jMeta["truncatedBytes"].asUInt() == 12, "result truncated on a character boundary");
+
+    session.SetServerMaxResultBytes(3, false);
+    auto rejected = fnResult();
+    session.LimitToolResult(rejected);
+    Expect(rejected.bIsError && rejected.vecTextContent.size() == 1, "oversized result rejected");
+
+    session.SetServerMaxResultBytes(0, true);
+}
+
+static void TestRecordingTransport()
+{
+    const std::string strPath = "tinymcp_smoketest_recording.jsonl";
+    std::remove(strPath.c_str());
+    auto spMemory = std::make_shared<MCP::CMemoryTransport>();
+    {
+        MCP::CRecordingTransport recorder(spMemory, strPath, { "token" });
+        spMemory->PushInput(R"({"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"login","arguments":{"user":"u","token":"s3cret"}}})");
+        spMemory->PushInput("not json");
+        std::string strIn;
+        recorder.Read(strIn);
+        recorder.Read(strIn);
+        recorder.Write("{\"id\":1,\"jsonrpc\":\"2.0\",\"result\":{}}\n");
+    }
+
+    std::vector<std::string> vecInput;
+    Expect(MCP::ERRNO_OK == MCP::CRecordingTransport::LoadRecordedInput(strPath, vecInput) && vecInput.size() == 2, "recorded input loaded");
+    Expect(vecInput.size() == 2 && vecInput[0].find("s3cret") == std::string::npos && vecInput[0].find("\"token\":\"***\"") != std::string::npos
+        && vecInput[0].find("\"user\":\"u\"") != std::string::npos, "recorded tools/call arguments redacted");
+    Expect(vecInput.size() == 2 && vecInput[1] == "not json", "malformed input recorded verbatim");
+    std::remove(strPath.c_str());
+}
+
+static void TestValidateServerTools()
+{
+    auto& session = MCP::CMCPSession::GetInstance();
+    MCP::Tool good;
+    good.strName = "get_status";
+    good.jInputSchema = Json::Value(Json::objectValue);
+    MCP::Tool unnamed = good;
+    unnamed.strName.clear();
+    MCP::Tool badSchema = good;
+    badSchema.strName = "bad_schema";
+    badSchema.jInputSchema = Json::Value("object");
+    MCP::Tool spaced = good;
+    spaced.strName = "get status";
+    session.SetServerTools({ good, unnamed, badSchema, spaced, good });
+
+    std::vector<std::string> vecIssues;
+    session.ValidateServerTools(vecIssues);
+    Expect(vecIssues.size() == 4 && vecIssues[0] == "tools[1]: missing name"
+        && vecIssues[1] == "tools[2] bad_schema: inputSchema must be an object"
+        && vecIssues[2].find("tools[3] get status: name must be") == 0
+        && vecIssues[3] == "tools[4] get_status: duplicate name, first registered as tools[0]", "tool issues reported");
+
+    session.SetServerTools({ good });
+    vecIssues.clear();
+    session.ValidateServerTools(vecIssues);
+    Expect(vecIssues.empty(), "valid tools report no issues");
+    session.SetServerTools({});
+}
+
+static void TestDuplicateToolPolicy()
+{
+    auto spTransport = std::make_shared<MCP::CMemoryTransport>();
+    auto& session = MCP::CMCPSession::GetInstance();
+    session.SetTransport(spTransport);
+    MCP::Tool first;
+    first.strName = "read";
+    first.strDescription = "first";
+    first.jInputSchema = Json::Value(Json::objectValue);
+    MCP::Tool other = first;
+    other.strName = "write";
+    MCP::Tool second = first;
+    second.strDescription = "second";
+    MCP::Tool taken = first;
+    taken.strName = "read_2";
+    auto describe = [&session]()
+    {
+        std::string strTools;
+        for (const auto& tool : session.GetServerTools())
+            strTools += tool.strName + "=" + tool.strDescription + ";";
+        return strTools;
+    };
+
+    session.SetServerTools({ first, other, second });
+    Expect(describe() == "read=first;write=first;read=second;", "duplicates kept by default: " + describe());
+    session.SetServerDuplicateToolPolicy(MCP::CMCPSession::DuplicateToolPolicy_FirstWins);
+    session.SetServerTools({ first, other, second });
+    Expect(describe() == "read=first;write=first;", "first definition wins: " + describe());
+    session.SetServerDuplicateToolPolicy(MCP::CMCPSession::DuplicateToolPolicy_LastWins);
+    session.SetServerTools({ first, other, second });
+    Expect(describe() == "read=second;write=first;", "last definition wins in place: " + describe());
+    session.SetServerDuplicateToolPolicy(MCP::CMCPSession::DuplicateToolPolicy_Suffix);
+    std::vector<std::pair<std::size_t, std::string>> vecRenamed;
+    session.SetServerTools({ first, other, second, taken }, &vecRenamed);
+    Expect(describe() == "read=first;write=first;read_3=second;read_2=first;", "duplicate renamed around given names: " + describe());
+    Expect(vecRenamed.size() == 1 && vecRenamed[0].first == 2 && vecRenamed[0].second == "read_3", "renamed tool reported");
+    session.SetServerDuplicateToolPolicy(MCP::CMCPSession::DuplicateToolPolicy_Error);
+    Expect(MCP::ERRNO_INVALID_PARAMS == session.SetServerTools({ first, second }) && describe() == "read=first;write=first;read_3=second;read_2=first;", "duplicate rejected, tools unchanged");
+
+    auto vecErrors = spTransport->GetErrors();
+    Expect(vecErrors.size() == 5 && vecErrors[0] == "tools[2] read: duplicate name, first registered as tools[0], both kept"
+        && vecErrors[3] == "tools[2] read: duplicate name, first registered as tools[0], registered as read_3"
+        && vecErrors[4] == "tools[1] read: duplicate name, first registered as tools[0], no tools registered", "a warning per duplicate");
+
+    // The renamed definition is callable once its task is registered under the reported name
+    session.SetServerDuplicateToolPolicy(MCP::CMCPSession::DuplicateToolPolicy_Suffix);
+    session.SetServerTools({ first, second }, &vecRenamed);
+    Expect(vecRenamed.size() == 1 && vecRenamed[0].second == "read_2", "second read renamed: " + std::to_string(vecRenamed.size()));
+    session.SetServerCallToolsTasks({ { "read", std::make_shared<CArgumentsTask>(nullptr) }, { "read_2", std::make_shared<CToolErrorTask>(nullptr) } });
+    {
+        CTestSession client;
+        std::string strOut = client.Request(CallTool(1, "read_2"));
+        Expect(HasId(strOut, 1) && strOut.find("relay stuck") != std::string::npos, "renamed tool runs its own task: " + strOut);
+        strOut = client.Request(CallTool(2, "read", R"({"n":1})"));
+        Expect(EchoedArguments(strOut)["n"].asInt() == 1, "original tool keeps its task: " + strOut);
+    }
+    session.SetServerCallToolsTasks({});
+    session.SetServerDuplicateToolPolicy(MCP::CMCPSession::DuplicateToolPolicy_Keep);
+    session.SetServerTools({});
+}
+
+static void TestLocalizedToolDescription()
+{
+    MCP::Tool tool;
+    tool.strDescription = "Turn the light on";
+    tool.hashLocalizedDescriptions["de"] = "Licht einschalten";
+    tool.hashLocalizedDescriptions["pt-BR"] = "Acender a luz";
+    Expect(tool.GetLocalizedDescription("de-AT") == "Licht einschalten" && tool.GetLocalizedDescription("pt-BR") == "Acender a luz",
+        "localized description picked by locale and language");
+    Expect(tool.GetLocalizedDescription("fr") == "Turn the light on" && tool.GetLocalizedDescription("") == "Turn the light on",
+        "default description without a matching locale");
+
+    MCP::InitializeRequest request(false);
+    Expect(MCP::ERRNO_OK == request.Deserialize(R"({"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","clientInfo":{"name":"c","version":"1"},"_meta":{"locale":"de-AT"}}})")
+        && request.strLocale == "de-AT", "locale hint read from initialize");
+}
+
+static void TestListToolsResultSkipsInvalidTools()
+{
+    MCP::ListToolsResult result(false);
+    int iErrCode = result.Deserialize(R"({"jsonrpc":"2.0","id":1,"result":{"tools":[)"
+        R"({"name":"ok","inputSchema":{"type":"object"}},)"
+        R"({"inputSchema":{"type":"object"}},)"
+        R"({"name":"bad_schema","inputSchema":{"type":"string"}},)"
+        R"({"name":"bad_title","title":7,"inputSchema":{}},)"
+        R"("not a tool",)"
+        R"({"name":"also_ok","description":"d","inputSchema":{}}],"nextCursor":"c"}})");
+    Expect(MCP::ERRNO_OK == iErrCode && result.vecTools.size() == 2 && result.vecTools[0].strName == "ok"
+        && result.vecTools[1].strName == "also_ok" && result.strNextCursor == "c", "valid tools kept");
+    Expect(result.vecInvalidTools.size() == 4 && result.vecInvalidTools[0] == "tools[1]: missing name"
+        && result.vecInvalidTools[1].find("tools[2] bad_schema: inputSchema type") == 0
+        && result.vecInvalidTools[2] == "tools[3] bad_title: title must be a string", "malformed tools reported");
+}
+
+class CCollectingLogger : public MCP::CMCPAuditLogger
+{
+public:
+    // Tool call entries are recorded from both the session and the async task thread
+    int Record(const Json::Value& jEntry) override
+    {
+        const std::lock_guard<std::mutex> _lock(m_mtxEntries);
+        vecEntries.push_back(jEntry);
+        return MCP::ERRNO_OK;
+    }
+
+    std::vector<Json::Value> vecEntries;
+
+private:
+    std::mutex m_mtxEntries;
+};
+
+static void TestAuditToolCalls()
+{
+    auto& session = MCP::CMCPSession::GetInstance();
+    MCP::Tool login;
+    login.strName = "login";
+    Json::Reader().parse(R"({"type":"object","properties":{"count":{"type":"integer"}}})", login.jInputSchema);
+    MCP::Tool failing = login;
+    failing.strName = "failing";
+    session.SetServerTools({ login, failing });
+    session.SetServerCallToolsTasks({ { "login", std::make_shared<CArgumentsTask>(nullptr) }, { "failing", std::make_shared<CToolErrorTask>(nullptr) } });
+    auto spLogger = std::make_shared<CCollectingLogger>();
+    session.SetServerAuditLogger(spLogger);
+    session.SetServerAuditRedactedArguments({ "token" });
+    {
+        CTestSession client("auditor");
+        std::string strOut = client.Request(CallTool(1, "login", R"({"user":"u","token":"s3cret"})"));
+        Expect(HasId(strOut, 1) && strOut.find("s3cret") != std::string::npos, "audited call still gets its arguments: " + strOut);
+        strOut = client.Request(CallTool(2, "login", R"({"count":"x","token":"s3cret"})"));
+        Expect(HasId(strOut, 2) && strOut.find("\"code\":-32602") != std::string::npos, "mistyped call rejected: " + strOut);
+        strOut = client.Request(CallTool(3, "failing", R"({"count":1})"));
+        Expect(HasId(strOut, 3) && strOut.find("\"isError\":true") != std::string::npos, "tool error returned: " + strOut);
+    }
+    session.SetServerAuditLogger(nullptr);
+    session.SetServerAuditRedactedArguments({});
+    session.SetServerCallToolsTasks({});
+    session.SetServerTools({});
+
+    auto& vecEntries = spLogger->vecEntries;
+    Expect(vecEntries.size() == 3, "one audit entry per call: " + std::to_string(vecEntries.size()));
+    if (vecEntries.size() != 3)
+        return;
+    Expect(vecEntries[0]["outcome"].asString() == "ok" && vecEntries[0]["requestId"].asInt() == 1 && vecEntries[0]["client"].asString() == "auditor"
+        && vecEntries[0]["tool"].asString() == "login" && vecEntries[0]["arguments"]["user"].asString() == "u"
+        && vecEntries[0]["arguments"]["token"].asString() == "***" && !vecEntries[0].isMember("code") && vecEntries[0]["time"].isString(), "ok call audited, token redacted");
+    Expect(vecEntries[1]["outcome"].asString() == "rejected" && vecEntries[1]["code"].asInt() == MCP::ERRNO_INVALID_PARAMS
+        && vecEntries[1]["message"].asString().find("argument 'count' must be integer") != std::string::npos
+        && vecEntries[1]["arguments"]["token"].asString() == "***", "rejected call audited with its reason");
+    Expect(vecEntries[2]["outcome"].asString() == "tool_error" && vecEntries[2]["tool"].asString() == "failing", "tool error audited");
+}
+
+static void TestDeadLetterNotification()
+{
+    auto& session = MCP::CMCPSession::GetInstance();
+    auto spLogger = std::make_shared<CCollectingLogger>();
+    session.SetServerDeadLetterLogger(spLogger);
+
+    // No client has initialized, so there is nobody to deliver to
+    MCP::LogNotification notification(false);
+    notification.strMethod = MCP::METHOD_NOTIFICATION_LOG;
+    notification.strLevel = "warning";
+    notification.strText = "list changed";
+    session.WriteNotification(notification);
+    Expect(spLogger->vecEntries.size() == 1 && spLogger->vecEntries[0]["method"] == "notifications/message"
+        && spLogger->vecEntries[0]["reason"] == "session not initialized"
+        && spLogger->vecEntries[0]["notification"]["params"]["data"] == "list changed", "undeliverable notification dead-lettered");
+
+    session.SetServerDeadLetterLogger(nullptr);
+}
+
+static void TestRedactFields()
+{
+    Json::Value jArguments;
+    Json::Reader().parse(R"({"pin":"1234","device":{"name":"d","pin":"5678","location":{"lat":1.5,"lon":2.5}},"list":[{"pin":"0"}],"lat":3})", jArguments);
+    MCP::RedactHelper::redact_fields(jArguments, { "pin", "device.location.lat", "missing.path" });
+    Expect(jArguments["pin"] == "***" && jArguments["device"]["pin"] == "***" && jArguments["list"][0]["pin"] == "***", "named field redacted at any depth");
+    Expect(jArguments["device"]["location"]["lat"] == "***" && jArguments["device"]["location"]["lon"].asDouble() == 2.5
+        && jArguments["lat"].asInt() == 3 && jArguments["device"]["name"] == "d", "dotted path redacted only where it leads");
+    Expect(!jArguments.isMember("missing"), "missing paths are not created");
+}
 
 int main() {
-    // Minimal link-time check: ensure we can include a header and link lib
+    TestPingResultShape();
+    TestExpandVariables();
+    TestErrorClassification();
+    TestErrorResponseShape();
+    TestToolTagsRoundTrip();
+    TestToolTitleRoundTrip();
+    TestCallToolMetaRoundTrip();
+    TestRecordingTransport();
+    TestRedactFields();
+    TestDeadLetterNotification();
+    TestListToolsResultSkipsInvalidTools();
+    TestLocalizedToolDescription();
+    TestValidateServerTools();
+    TestDuplicateToolPolicy();
+    TestToolResultLimit();
+    TestResourceLinkContent();
+    TestEmptyCallToolResult();
+    TestStartupSummary();
+    TestErrorVerbosity();
+    TestSessionOverMemoryTransport();
+    // A terminated session must shut its task thread down cleanly and accept a new client
+    TestSessionOverMemoryTransport();
+    TestSamplingOverMemoryTransport();
+    TestCapabilitiesOverride();
+    TestInitializeTimeout();
+    TestStdioInitializeTimeout();
+    TestMaxConcurrentCalls();
+    TestArgumentCoercion();
+    TestJsonRpcVersion();
+    TestIdempotencyKey();
+    TestToolNotFound();
+    TestClientInstructions();
+    TestInstructionsFile();
+    TestAuditToolCalls();
+    TestTerminateResetsSession();
+    TestArgumentDefaults();
+    TestArgumentLimits();
+
+    if (g_iFailures > 0)
+        return 1;
+
     std::cout << "TinyMCP smoketest OK" << std::endl;
     return 0;
 }
-